@@ -0,0 +1,113 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Entry is one change recorded in the audit log.
+type Entry struct {
+	Time      time.Time `json:"time"`
+	User      string    `json:"user"`
+	Action    string    `json:"action"`           // e.g. "zone.save", "record.delete", "reload"
+	Target    string    `json:"target,omitempty"` // zone domain or file name
+	Detail    string    `json:"detail,omitempty"`
+	RequestID string    `json:"request_id,omitempty"`
+}
+
+// Logger appends entries to a file as JSON lines. A Logger with an empty
+// path records nothing, so callers don't need to check whether auditing is
+// enabled.
+type Logger struct {
+	path string
+	mu   sync.Mutex
+}
+
+func New(path string) *Logger {
+	return &Logger{path: path}
+}
+
+// Enabled reports whether entries are written anywhere.
+func (l *Logger) Enabled() bool {
+	return l.path != ""
+}
+
+// Path returns the audit log file.
+func (l *Logger) Path() string {
+	return l.path
+}
+
+// Log appends an entry, stamping it with the current time if unset.
+func (l *Logger) Log(e Entry) error {
+	if !l.Enabled() {
+		return nil
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	return f.Close()
+}
+
+// Tail returns the last n entries, newest first. Lines that don't parse are
+// skipped.
+func (l *Logger) Tail(n int) ([]Entry, error) {
+	if !l.Enabled() || n <= 0 {
+		return nil, nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	f, err := os.Open(l.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer f.Close()
+
+	ring := make([]Entry, 0, n)
+	start := 0
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 1<<20)
+	for sc.Scan() {
+		var e Entry
+		if json.Unmarshal(sc.Bytes(), &e) != nil {
+			continue
+		}
+		if len(ring) < n {
+			ring = append(ring, e)
+		} else {
+			ring[start] = e
+			start = (start + 1) % n
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+
+	entries := make([]Entry, 0, len(ring))
+	for i := len(ring) - 1; i >= 0; i-- {
+		entries = append(entries, ring[(start+i)%len(ring)])
+	}
+	return entries, nil
+}
@@ -1,6 +1,8 @@
 package auth
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"net/http"
 	"time"
 
@@ -8,43 +10,98 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
-const (
-	CookieName     = "jwt"
-	TokenExpiry    = 24 * time.Hour
-)
+const CookieName = "jwt"
+
+// MinPasswordLength is the shortest password accepted when changing it.
+const MinPasswordLength = 12
+
+// HashPassword hashes a new password for storage.
+func HashPassword(password string) ([]byte, error) {
+	return bcrypt.GenerateFromPassword([]byte(password), 12)
+}
 
 func VerifyPassword(password string, hash []byte) bool {
 	return bcrypt.CompareHashAndPassword(hash, []byte(password)) == nil
 }
 
-func GenerateToken(secret []byte) (string, error) {
+// PasswordGeneration derives a token generation from a password hash, so
+// changing the password changes it and retires the tokens issued before.
+func PasswordGeneration(hash []byte) string {
+	sum := sha256.Sum256(hash)
+	return hex.EncodeToString(sum[:8])
+}
+
+// Generations returns the current token generation of user, and false if
+// the user no longer exists.
+type Generations func(user string) (string, bool)
+
+// GenerateToken issues a session token for user, which is kept in the
+// "sub" claim, valid for ttl. gen is the user's current token generation.
+func GenerateToken(secret []byte, user, gen string, ttl time.Duration) (string, error) {
 	claims := jwt.MapClaims{
 		"authenticated": true,
-		"exp":           time.Now().Add(TokenExpiry).Unix(),
+		"sub":           user,
+		"gen":           gen,
+		"exp":           time.Now().Add(ttl).Unix(),
 		"iat":           time.Now().Unix(),
 	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString(secret)
 }
 
-func SetCookie(w http.ResponseWriter, tokenString string) {
-	http.SetCookie(w, &http.Cookie{
-		Name:     CookieName,
-		Value:    tokenString,
-		Path:     "/",
-		HttpOnly: true,
-		SameSite: http.SameSiteStrictMode,
-		MaxAge:   int(TokenExpiry.Seconds()),
-	})
+// Cookies scopes the manager's cookies so several instances under one
+// parent domain don't overwrite each other's sessions. Prefix is prepended
+// to every cookie name; Domain, if set, is sent as the cookie Domain.
+type Cookies struct {
+	Prefix string
+	Domain string
+}
+
+// Name returns the configured name for a base cookie name such as "jwt".
+func (cc Cookies) Name(base string) string {
+	return cc.Prefix + base
 }
 
-func ClearCookie(w http.ResponseWriter) {
-	http.SetCookie(w, &http.Cookie{
-		Name:     CookieName,
-		Value:    "",
+// New builds an httpOnly, SameSite=Strict cookie with the configured name
+// and domain. A negative maxAge deletes the cookie.
+func (cc Cookies) New(base, value string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     cc.Name(base),
+		Value:    value,
 		Path:     "/",
+		Domain:   cc.Domain,
 		HttpOnly: true,
 		SameSite: http.SameSiteStrictMode,
-		MaxAge:   -1,
-	})
+		MaxAge:   maxAge,
+	}
+}
+
+// SetCookie stores a session token in a cookie that expires with it.
+func SetCookie(w http.ResponseWriter, cc Cookies, tokenString string, ttl time.Duration) {
+	http.SetCookie(w, cc.New(CookieName, tokenString, int(ttl.Seconds())))
+}
+
+func ClearCookie(w http.ResponseWriter, cc Cookies) {
+	http.SetCookie(w, cc.New(CookieName, "", -1))
+}
+
+// TokenUser returns the user a verified token was issued to. Tokens from
+// before per-user logins carry no subject and belong to the master user.
+func TokenUser(token *jwt.Token) string {
+	if sub, err := token.Claims.GetSubject(); err == nil && sub != "" {
+		return sub
+	}
+	return MasterUser
+}
+
+// currentGeneration reports whether a verified token was issued since its
+// user's password last changed. Tokens from before generations carry none
+// and only match an empty generation.
+func currentGeneration(token *jwt.Token, gens Generations) bool {
+	var gen string
+	if claims, ok := token.Claims.(jwt.MapClaims); ok {
+		gen, _ = claims["gen"].(string)
+	}
+	want, ok := gens(TokenUser(token))
+	return ok && gen == want
 }
@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// MinSecretLength is the shortest JWT secret accepted during rotation.
+const MinSecretLength = 16
+
+// Keyring holds the JWT signing secrets. New tokens are signed with the
+// primary; tokens signed with either the primary or the secondary verify,
+// so the secret can be rotated without logging everyone out.
+type Keyring struct {
+	mu        sync.RWMutex
+	primary   []byte
+	secondary []byte
+}
+
+func NewKeyring(primary, secondary []byte) *Keyring {
+	return &Keyring{primary: primary, secondary: secondary}
+}
+
+// Primary returns the secret used to sign new tokens.
+func (k *Keyring) Primary() []byte {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.primary
+}
+
+// Rotate promotes secret to primary and demotes the current primary to
+// secondary. The previous secondary is dropped.
+func (k *Keyring) Rotate(secret []byte) error {
+	if len(secret) < MinSecretLength {
+		return fmt.Errorf("secret must be at least %d characters", MinSecretLength)
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.secondary = k.primary
+	k.primary = secret
+	return nil
+}
+
+// keyFunc verifies tokens against every secret currently accepted.
+func (k *Keyring) keyFunc(t *jwt.Token) (interface{}, error) {
+	if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+		return nil, jwt.ErrSignatureInvalid
+	}
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	keys := []jwt.VerificationKey{k.primary}
+	if len(k.secondary) > 0 {
+		keys = append(keys, k.secondary)
+	}
+	return jwt.VerificationKeySet{Keys: keys}, nil
+}
@@ -1,33 +1,93 @@
 package auth
 
 import (
+	"crypto/sha256"
+	"crypto/subtle"
 	"net/http"
+	"strings"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/labstack/echo/v4"
 )
 
-func Middleware(secret []byte) echo.MiddlewareFunc {
+func Middleware(keys *Keyring, cc Cookies, gens Generations) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
-			cookie, err := c.Cookie(CookieName)
+			cookie, err := c.Cookie(cc.Name(CookieName))
 			if err != nil || cookie.Value == "" {
 				return c.Redirect(http.StatusSeeOther, "/login")
 			}
 
-			token, err := jwt.Parse(cookie.Value, func(t *jwt.Token) (interface{}, error) {
-				if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-					return nil, jwt.ErrSignatureInvalid
-				}
-				return secret, nil
-			})
-			if err != nil || !token.Valid {
-				ClearCookie(c.Response().Writer)
+			token, err := jwt.Parse(cookie.Value, keys.keyFunc)
+			if err != nil || !token.Valid || !currentGeneration(token, gens) {
+				ClearCookie(c.Response().Writer, cc)
 				return c.Redirect(http.StatusSeeOther, "/login")
 			}
 
 			c.Set("authenticated", true)
+			c.Set("auth_method", "cookie")
+			c.Set("user", TokenUser(token))
+			if exp, err := token.Claims.GetExpirationTime(); err == nil && exp != nil {
+				c.Set("session_exp", exp.Time)
+			}
+			return next(c)
+		}
+	}
+}
+
+// APIMiddleware authenticates JSON API requests. It accepts a session token
+// either as the login cookie or as an "Authorization: Bearer" header, and
+// answers 401 with a JSON body instead of redirecting to the login page. A
+// bearer value may also be one of the long-lived API tokens.
+func APIMiddleware(keys *Keyring, cc Cookies, gens Generations, tokens []string) echo.MiddlewareFunc {
+	hashes := make([][32]byte, len(tokens))
+	for i, t := range tokens {
+		hashes[i] = sha256.Sum256([]byte(t))
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			raw, method := "", ""
+			if bearer, ok := strings.CutPrefix(c.Request().Header.Get("Authorization"), "Bearer "); ok {
+				raw, method = strings.TrimSpace(bearer), "bearer"
+			} else if cookie, err := c.Cookie(cc.Name(CookieName)); err == nil {
+				raw, method = cookie.Value, "cookie"
+			}
+			if raw == "" {
+				return c.JSON(http.StatusUnauthorized, map[string]string{"error": "authentication required"})
+			}
+
+			if method == "bearer" && matchToken(hashes, raw) {
+				c.Set("authenticated", true)
+				c.Set("auth_method", "api_token")
+				c.Set("user", "api")
+				return next(c)
+			}
+
+			token, err := jwt.Parse(raw, keys.keyFunc)
+			if err != nil || !token.Valid || !currentGeneration(token, gens) {
+				return c.JSON(http.StatusUnauthorized, map[string]string{"error": "invalid or expired token"})
+			}
+
+			c.Set("authenticated", true)
+			c.Set("auth_method", method)
+			c.Set("user", TokenUser(token))
+			if exp, err := token.Claims.GetExpirationTime(); err == nil && exp != nil {
+				c.Set("session_exp", exp.Time)
+			}
 			return next(c)
 		}
 	}
 }
+
+// matchToken reports whether raw is one of the API tokens. Hashing first
+// makes every comparison the same length, and all tokens are compared so
+// the timing doesn't reveal which one matched.
+func matchToken(hashes [][32]byte, raw string) bool {
+	sum := sha256.Sum256([]byte(raw))
+	match := 0
+	for _, h := range hashes {
+		match |= subtle.ConstantTimeCompare(sum[:], h[:])
+	}
+	return match == 1
+}
@@ -0,0 +1,49 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestMiddlewareGeneration(t *testing.T) {
+	keys := NewKeyring([]byte("0123456789abcdef"), nil)
+	gens := map[string]string{"alice": "one"}
+	mw := Middleware(keys, Cookies{}, func(user string) (string, bool) {
+		gen, ok := gens[user]
+		return gen, ok
+	})
+	handler := mw(func(c echo.Context) error { return c.NoContent(http.StatusOK) })
+
+	tests := []struct {
+		name string
+		user string
+		gen  string
+		want int
+	}{
+		{"current generation", "alice", "one", http.StatusOK},
+		{"password changed since", "alice", "zero", http.StatusSeeOther},
+		{"no generation", "alice", "", http.StatusSeeOther},
+		{"unknown user", "bob", "", http.StatusSeeOther},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := GenerateToken(keys.Primary(), tt.user, tt.gen, time.Hour)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.AddCookie(&http.Cookie{Name: CookieName, Value: token})
+			rec := httptest.NewRecorder()
+			if err := handler(echo.New().NewContext(req, rec)); err != nil {
+				t.Fatal(err)
+			}
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// MasterUser is the user name recorded for logins with the master password.
+const MasterUser = "master"
+
+// dummyHash is compared against when a user name is unknown, so a failed
+// login takes as long whether or not the user exists.
+var dummyHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("unknown user"), 12)
+	return hash
+})
+
+// Users holds per-user bcrypt password hashes loaded from a JSON file that
+// maps user names to hashes:
+//
+//	{"alice": "$2a$12$...", "bob": "$2a$12$..."}
+type Users struct {
+	path string
+
+	mu     sync.RWMutex
+	hashes map[string][]byte
+}
+
+// LoadUsers reads a users file. Every entry must be a bcrypt hash, so
+// plaintext passwords never sit in the file.
+func LoadUsers(path string) (*Users, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read users file: %w", err)
+	}
+	var entries map[string]string
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse users file %s: %w", path, err)
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("users file %s has no users", path)
+	}
+
+	hashes := make(map[string][]byte, len(entries))
+	for name, hash := range entries {
+		if name == "" || strings.ContainsAny(name, " \t\r\n") {
+			return nil, fmt.Errorf("users file %s: invalid user name %q", path, name)
+		}
+		if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+			return nil, fmt.Errorf("users file %s: password for %q is not a bcrypt hash", path, name)
+		}
+		hashes[name] = []byte(hash)
+	}
+	return &Users{path: path, hashes: hashes}, nil
+}
+
+// Verify reports whether password is correct for the named user.
+func (u *Users) Verify(name, password string) bool {
+	u.mu.RLock()
+	hash, ok := u.hashes[name]
+	u.mu.RUnlock()
+	if !ok {
+		VerifyPassword(password, dummyHash())
+		return false
+	}
+	return VerifyPassword(password, hash)
+}
+
+// Generation returns the token generation of the named user, and false if
+// the user isn't in the file.
+func (u *Users) Generation(name string) (string, bool) {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+	hash, ok := u.hashes[name]
+	if !ok {
+		return "", false
+	}
+	return PasswordGeneration(hash), true
+}
+
+// SetPassword replaces a user's password hash and rewrites the users file.
+func (u *Users) SetPassword(name string, hash []byte) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if _, ok := u.hashes[name]; !ok {
+		return fmt.Errorf("unknown user %q", name)
+	}
+
+	entries := make(map[string]string, len(u.hashes))
+	for n, h := range u.hashes {
+		entries[n] = string(h)
+	}
+	entries[name] = string(hash)
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeSecretFile(u.path, append(data, '\n')); err != nil {
+		return err
+	}
+	u.hashes[name] = hash
+	return nil
+}
+
+// SavePasswordHash stores the master password hash in path.
+func SavePasswordHash(path string, hash []byte) error {
+	return writeSecretFile(path, append(append([]byte{}, hash...), '\n'))
+}
+
+// writeSecretFile replaces path atomically with an owner-only file.
+func writeSecretFile(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".secret-*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	tmpPath := tmp.Name()
+	if _, err = tmp.Write(data); err == nil {
+		err = tmp.Sync()
+	}
+	if err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to replace %s: %w", path, err)
+	}
+	// Flush the rename too, so a crash can't bring back the old password;
+	// the new file is in place either way, so a failure is only logged
+	if err := syncDir(filepath.Dir(path)); err != nil {
+		log.Printf("wrote %s, but %v", path, err)
+	}
+	return nil
+}
+
+// syncDir flushes a directory entry change such as a rename to disk.
+// Filesystems that can't sync directories are not treated as an error.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil && !errors.Is(err, syscall.EINVAL) && !errors.Is(err, syscall.ENOTSUP) {
+		return fmt.Errorf("failed to sync %s: %w", dir, err)
+	}
+	return nil
+}
@@ -2,22 +2,86 @@ package config
 
 import (
 	"fmt"
+	"net"
+	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/labstack/gommon/bytes"
 	"golang.org/x/crypto/bcrypt"
 )
 
+// Reload policies controlling whether saves reload CoreDNS.
+const (
+	ReloadManual   = "manual"   // saves never reload; use the explicit reload action
+	ReloadAlways   = "always"   // every successful save reloads
+	ReloadOptional = "optional" // the save form decides
+)
+
+// Ways of telling CoreDNS to pick up changes, for RELOAD_STRATEGY.
+const (
+	ReloadSignal  = "signal"  // send SIGUSR1, handled by the reload plugin
+	ReloadExec    = "exec"    // run RELOAD_COMMAND inside the container
+	ReloadRestart = "restart" // restart the container
+)
+
+// SOA serial formats for SOA_SERIAL_MODE.
+const (
+	SerialDate  = "date"  // YYYYMMDDNN
+	SerialEpoch = "epoch" // Unix time in seconds
+)
+
 type Config struct {
 	CorefilePath         string
 	ZoneDir              string
 	MasterPasswordHash   []byte
+	MasterPasswordSalted bool // hashed from a plaintext MASTER_PASSWORD, so the hash differs on every start
+	PasswordFile         string
 	JWTSecret            []byte
+	JWTSecretSecondary   []byte
 	CoreDNSContainerName string
+	CoreDNSQueryAddr     string
+	CoreDNSBinary        string
+	ValidateBeforeReload bool
+	ReloadStrategy       string
+	ReloadCommand        []string
 	Port                 string
+	ListenAddrs          []string
+	StatusCacheTTL       time.Duration
+	StateDir             string
+	ReloadPolicy         string
+	ManagedHeader        bool
+	StrictRecordNames    bool
+	NormalizeTargets     bool
+	DashboardWidgets     []string
+	PublicZones          []string
+	InternalZones        []string
+	BodyLimit            string
+	RestoreBodyLimit     string
+	CookiePrefix         string
+	CookieDomain         string
+	SOASerialMode        string
+	APITokens            []string
+	UsersFile            string
+	AuditLog             string
+	SessionTTL           time.Duration
+	RememberTTL          time.Duration
+	BackupDir            string
+	BackupKeep           int
+	GitRepoDir           string
+	WebhookURL           string
+	WebhookSecret        []byte
+	ReadOnly             bool
 }
 
+// DashboardWidgetNames lists the dashboard sections DASHBOARD_WIDGETS can
+// enable, in their default order.
+var DashboardWidgetNames = []string{"status", "corefile", "zones", "build", "actions", "zone_list", "types"}
+
 func Load() (*Config, error) {
 	corefilePath := os.Getenv("COREFILE_PATH")
 	if corefilePath == "" {
@@ -52,28 +116,318 @@ func Load() (*Config, error) {
 		containerName = "coredns"
 	}
 
+	// Where live lookups reach CoreDNS; the container name only resolves
+	// when the manager shares its Docker network
+	queryAddr := os.Getenv("COREDNS_QUERY_ADDR")
+	if queryAddr == "" {
+		queryAddr = containerName
+	}
+	if _, _, err := net.SplitHostPort(queryAddr); err != nil {
+		queryAddr = net.JoinHostPort(strings.Trim(queryAddr, "[]"), "53")
+	}
+	if _, port, _ := net.SplitHostPort(queryAddr); port == "" {
+		return nil, fmt.Errorf("COREDNS_QUERY_ADDR must be host or host:port: %q", os.Getenv("COREDNS_QUERY_ADDR"))
+	}
+
+	validateBeforeReload := false
+	if v := os.Getenv("VALIDATE_BEFORE_RELOAD"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("VALIDATE_BEFORE_RELOAD must be true or false: %q", v)
+		}
+		validateBeforeReload = b
+	}
+
+	reloadStrategy := os.Getenv("RELOAD_STRATEGY")
+	if reloadStrategy == "" {
+		reloadStrategy = ReloadSignal
+	}
+	if reloadStrategy != ReloadSignal && reloadStrategy != ReloadExec && reloadStrategy != ReloadRestart {
+		return nil, fmt.Errorf("RELOAD_STRATEGY must be signal, exec, or restart: %q", reloadStrategy)
+	}
+	reloadCommand := strings.Fields(os.Getenv("RELOAD_COMMAND"))
+	if reloadStrategy == ReloadExec && len(reloadCommand) == 0 {
+		return nil, fmt.Errorf("RELOAD_COMMAND is required when RELOAD_STRATEGY is exec")
+	}
+
+	apiTokens := splitList(os.Getenv("API_TOKENS"))
+	for i, t := range apiTokens {
+		// The token itself is a secret, so only its position is reported
+		if len(t) < 16 {
+			return nil, fmt.Errorf("API_TOKENS entries must be at least 16 characters: entry %d has %d", i+1, len(t))
+		}
+	}
+
+	sessionTTL := 24 * time.Hour
+	if v := os.Getenv("SESSION_TTL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < time.Minute {
+			return nil, fmt.Errorf("SESSION_TTL must be a duration of at least 1m: %q", v)
+		}
+		sessionTTL = d
+	}
+	rememberTTL := max(30*24*time.Hour, sessionTTL)
+	if v := os.Getenv("REMEMBER_TTL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < sessionTTL {
+			return nil, fmt.Errorf("REMEMBER_TTL must be a duration no shorter than SESSION_TTL: %q", v)
+		}
+		rememberTTL = d
+	}
+
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080"
 	}
 
+	statusCacheTTL := 5 * time.Second
+	if v := os.Getenv("STATUS_CACHE_TTL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("STATUS_CACHE_TTL must be a non-negative duration (e.g. 5s): %q", v)
+		}
+		statusCacheTTL = d
+	}
+
+	reloadPolicy := os.Getenv("RELOAD_POLICY")
+	if reloadPolicy == "" {
+		reloadPolicy = ReloadOptional
+	}
+	switch reloadPolicy {
+	case ReloadManual, ReloadAlways, ReloadOptional:
+	default:
+		return nil, fmt.Errorf("RELOAD_POLICY must be one of manual, always, optional: %q", reloadPolicy)
+	}
+
+	serialMode := os.Getenv("SOA_SERIAL_MODE")
+	if serialMode == "" {
+		serialMode = SerialDate
+	}
+	if serialMode != SerialDate && serialMode != SerialEpoch {
+		return nil, fmt.Errorf("SOA_SERIAL_MODE must be date or epoch: %q", serialMode)
+	}
+
+	managedHeader := false
+	if v := os.Getenv("MANAGED_HEADER"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("MANAGED_HEADER must be true or false: %q", v)
+		}
+		managedHeader = b
+	}
+
+	strictRecordNames := true
+	if v := os.Getenv("STRICT_RECORD_NAMES"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("STRICT_RECORD_NAMES must be true or false: %q", v)
+		}
+		strictRecordNames = b
+	}
+
+	// Off by default: turning it on changes what existing relative targets
+	// like "mail.other" mean the next time the zone is saved
+	normalizeTargets := false
+	if v := os.Getenv("NORMALIZE_TARGETS"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("NORMALIZE_TARGETS must be true or false: %q", v)
+		}
+		normalizeTargets = b
+	}
+
+	readOnly := false
+	if v := os.Getenv("READ_ONLY"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("READ_ONLY must be true or false: %q", v)
+		}
+		readOnly = b
+	}
+
+	backupKeep := 20
+	if v := os.Getenv("BACKUP_KEEP"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("BACKUP_KEEP must be a non-negative integer: %q", v)
+		}
+		backupKeep = n
+	}
+
+	webhookURL := os.Getenv("WEBHOOK_URL")
+	if webhookURL != "" {
+		u, err := url.Parse(webhookURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("WEBHOOK_URL must be an http or https URL: %q", webhookURL)
+		}
+	}
+
+	dashboardWidgets := DashboardWidgetNames
+	if v := os.Getenv("DASHBOARD_WIDGETS"); v != "" {
+		dashboardWidgets = nil
+		for _, w := range strings.Split(v, ",") {
+			w = strings.TrimSpace(w)
+			if w == "" {
+				continue
+			}
+			if !slices.Contains(DashboardWidgetNames, w) {
+				return nil, fmt.Errorf("DASHBOARD_WIDGETS: unknown widget %q (allowed: %s)", w, strings.Join(DashboardWidgetNames, ", "))
+			}
+			dashboardWidgets = append(dashboardWidgets, w)
+		}
+	}
+
+	bodyLimit, err := sizeEnv("BODY_LIMIT", "10M")
+	if err != nil {
+		return nil, err
+	}
+	restoreBodyLimit, err := sizeEnv("RESTORE_BODY_LIMIT", "512M")
+	if err != nil {
+		return nil, err
+	}
+
+	// LISTEN_ADDR overrides PORT with one or more explicit addresses, e.g.
+	// "[::]:8080" or "0.0.0.0:8080,[::]:8080" for separate v4/v6 listeners
+	listenAddrs := []string{":" + port}
+	if v := os.Getenv("LISTEN_ADDR"); v != "" {
+		listenAddrs = splitList(v)
+		for _, addr := range listenAddrs {
+			if _, p, err := net.SplitHostPort(addr); err != nil || p == "" {
+				return nil, fmt.Errorf("LISTEN_ADDR entries must be host:port (e.g. [::]:8080): %q", addr)
+			}
+		}
+		if len(listenAddrs) == 0 {
+			return nil, fmt.Errorf("LISTEN_ADDR is empty")
+		}
+	}
+
+	// Cookie scoping lets several instances share a parent domain
+	cookiePrefix := os.Getenv("COOKIE_PREFIX")
+	if strings.IndexFunc(cookiePrefix, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.')
+	}) >= 0 {
+		return nil, fmt.Errorf("COOKIE_PREFIX may only contain letters, digits, '-', '_', and '.': %q", cookiePrefix)
+	}
+	if strings.HasPrefix(cookiePrefix, "__") {
+		return nil, fmt.Errorf("COOKIE_PREFIX must not start with \"__\" (reserved for __Host-/__Secure- cookies): %q", cookiePrefix)
+	}
+	cookieDomain := os.Getenv("COOKIE_DOMAIN")
+	if cookieDomain != "" && (strings.ContainsAny(cookieDomain, " ;,/:") || strings.Trim(cookieDomain, ".") == "") {
+		return nil, fmt.Errorf("COOKIE_DOMAIN must be a bare domain name such as example.com: %q", cookieDomain)
+	}
+
+	// Optional directory for the manager's own state (users, audit log, ...)
+	stateDir := os.Getenv("STATE_DIR")
+	if stateDir != "" {
+		if err := os.MkdirAll(stateDir, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create STATE_DIR: %w", err)
+		}
+	}
+
+	// Previous versions of every file the manager overwrites. They are the
+	// manager's own state, so they default to STATE_DIR rather than the
+	// zone directory CoreDNS reads, and to the user's state directory
+	// without it
+	backupDir := os.Getenv("BACKUP_DIR")
+	if backupDir == "" && stateDir != "" {
+		backupDir = filepath.Join(stateDir, "backups")
+	}
+	if backupDir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("no backup directory: set BACKUP_DIR or STATE_DIR (%v)", err)
+		}
+		backupDir = filepath.Join(home, ".local", "state", "simple-coredns-manager", "backups")
+	}
+
+	// A password changed through the UI is kept in PASSWORD_FILE and
+	// overrides MASTER_PASSWORD
+	passwordFile := os.Getenv("PASSWORD_FILE")
+	if passwordFile == "" && stateDir != "" {
+		passwordFile = filepath.Join(stateDir, "master_password.hash")
+	}
+
 	var passwordHash []byte
-	if strings.HasPrefix(masterPassword, "$2a$") || strings.HasPrefix(masterPassword, "$2b$") {
+	salted := false
+	if data, err := os.ReadFile(passwordFile); passwordFile != "" && err == nil {
+		passwordHash = []byte(strings.TrimSpace(string(data)))
+		if _, err := bcrypt.Cost(passwordHash); err != nil {
+			return nil, fmt.Errorf("PASSWORD_FILE %s does not hold a bcrypt hash", passwordFile)
+		}
+	} else if strings.HasPrefix(masterPassword, "$2a$") || strings.HasPrefix(masterPassword, "$2b$") {
 		passwordHash = []byte(masterPassword)
 	} else {
 		hash, err := bcrypt.GenerateFromPassword([]byte(masterPassword), 12)
 		if err != nil {
 			return nil, fmt.Errorf("failed to hash master password: %w", err)
 		}
-		passwordHash = hash
+		passwordHash, salted = hash, true
 	}
 
 	return &Config{
 		CorefilePath:         corefilePath,
 		ZoneDir:              zoneDir,
 		MasterPasswordHash:   passwordHash,
+		MasterPasswordSalted: salted,
+		PasswordFile:         passwordFile,
 		JWTSecret:            []byte(jwtSecret),
+		JWTSecretSecondary:   []byte(os.Getenv("JWT_SECRET_SECONDARY")),
 		CoreDNSContainerName: containerName,
+		CoreDNSQueryAddr:     queryAddr,
+		CoreDNSBinary:        os.Getenv("COREDNS_BINARY"),
+		ValidateBeforeReload: validateBeforeReload,
+		ReloadStrategy:       reloadStrategy,
+		ReloadCommand:        reloadCommand,
 		Port:                 port,
+		ListenAddrs:          listenAddrs,
+		StatusCacheTTL:       statusCacheTTL,
+		StateDir:             stateDir,
+		ReloadPolicy:         reloadPolicy,
+		ManagedHeader:        managedHeader,
+		StrictRecordNames:    strictRecordNames,
+		NormalizeTargets:     normalizeTargets,
+		DashboardWidgets:     dashboardWidgets,
+		PublicZones:          splitList(os.Getenv("PUBLIC_ZONES")),
+		InternalZones:        splitList(os.Getenv("INTERNAL_ZONES")),
+		BodyLimit:            bodyLimit,
+		RestoreBodyLimit:     restoreBodyLimit,
+		CookiePrefix:         cookiePrefix,
+		CookieDomain:         cookieDomain,
+		SOASerialMode:        serialMode,
+		APITokens:            apiTokens,
+		UsersFile:            os.Getenv("USERS_FILE"),
+		AuditLog:             os.Getenv("AUDIT_LOG"),
+		SessionTTL:           sessionTTL,
+		RememberTTL:          rememberTTL,
+		BackupDir:            backupDir,
+		BackupKeep:           backupKeep,
+		GitRepoDir:           os.Getenv("GIT_REPO_DIR"),
+		WebhookURL:           webhookURL,
+		WebhookSecret:        []byte(os.Getenv("WEBHOOK_SECRET")),
+		ReadOnly:             readOnly,
 	}, nil
 }
+
+// splitList parses a comma-separated list, dropping empty entries.
+func splitList(v string) []string {
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// sizeEnv reads a byte size such as "10M" or "1G" from an environment
+// variable, falling back to def when unset.
+func sizeEnv(name, def string) (string, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return def, nil
+	}
+	if n, err := bytes.Parse(v); err != nil || n <= 0 {
+		return "", fmt.Errorf("%s must be a positive size (e.g. 10M): %q", name, v)
+	}
+	return v, nil
+}
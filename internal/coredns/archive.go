@@ -0,0 +1,203 @@
+package coredns
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxArchiveSize caps the total uncompressed size of a restored archive.
+const maxArchiveSize = 512 << 20
+
+// corefileEntry is the archive name of the Corefile. Zone files keep their
+// db.<domain> names; files pulled in by import are not included.
+const corefileEntry = "Corefile"
+
+// RestoreResult lists what RestoreArchive wrote.
+type RestoreResult struct {
+	Corefile bool
+	Zones    []string
+}
+
+// ExportArchive writes the Corefile and every zone file as a gzipped tar.
+func ExportArchive(w io.Writer, cf *CorefileManager, zm *ZoneManager) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	now := time.Now()
+
+	add := func(name, content string) error {
+		hdr := &tar.Header{Name: name, Mode: 0o644, Size: int64(len(content)), ModTime: now, Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		_, err := io.WriteString(tw, content)
+		return err
+	}
+
+	corefile, err := cf.Read()
+	if err != nil {
+		return err
+	}
+	if err := add(corefileEntry, corefile); err != nil {
+		return err
+	}
+	domains, err := zm.List()
+	if err != nil {
+		return err
+	}
+	for _, domain := range domains {
+		raw, err := zm.ReadRaw(domain)
+		if err != nil {
+			return fmt.Errorf("failed to read zone %s: %w", domain, err)
+		}
+		if err := add(zonePrefix+domain, raw); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// RestoreArchive reads an archive written by ExportArchive and validates every
+// file in it before writing any. If a write then fails, the files already
+// written are put back, so the restore applies completely or not at all.
+// Zones missing from the archive are left alone. Restored zones keep serials
+// moving forward, as with a backup restore.
+func RestoreArchive(r io.Reader, cf *CorefileManager, zm *ZoneManager) (*RestoreResult, error) {
+	corefile, zones, err := readArchive(r)
+	if err != nil {
+		return nil, err
+	}
+	if corefile == nil && len(zones) == 0 {
+		return nil, fmt.Errorf("archive contains no Corefile or zone files")
+	}
+
+	domains := make([]string, 0, len(zones))
+	for domain := range zones {
+		domains = append(domains, domain)
+	}
+	sort.Strings(domains)
+
+	if corefile != nil {
+		if err := cf.Validate(*corefile); err != nil {
+			return nil, fmt.Errorf("%s: %w", corefileEntry, err)
+		}
+	}
+	for _, domain := range domains {
+		if err := zm.Validate(domain, zones[domain]); err != nil {
+			return nil, fmt.Errorf("%s%s: %w", zonePrefix, domain, err)
+		}
+	}
+
+	// Remember what is there now, to roll back to
+	var rollback []func() error
+	undo := func() error {
+		var errs []error
+		for i := len(rollback) - 1; i >= 0; i-- {
+			if err := rollback[i](); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return errors.Join(errs...)
+	}
+
+	result := &RestoreResult{}
+	if corefile != nil {
+		prev, err := cf.Read()
+		if err != nil {
+			return nil, err
+		}
+		if err := cf.Write(*corefile); err != nil {
+			return nil, fmt.Errorf("failed to write Corefile: %w", err)
+		}
+		rollback = append(rollback, func() error { return cf.Write(prev) })
+		result.Corefile = true
+	}
+	for _, domain := range domains {
+		prev, err := zm.ReadRaw(domain)
+		existed := err == nil
+		if err := zm.Restore(domain, zones[domain]); err != nil {
+			if rerr := undo(); rerr != nil {
+				return nil, fmt.Errorf("failed to write zone %s: %w; putting back the files already restored also failed, so the configuration is partly restored: %v", domain, err, rerr)
+			}
+			return nil, fmt.Errorf("failed to write zone %s, nothing was restored: %w", domain, err)
+		}
+		if existed {
+			rollback = append(rollback, func() error { return zm.Restore(domain, prev) })
+		} else {
+			rollback = append(rollback, func() error { return zm.Delete(domain) })
+		}
+		result.Zones = append(result.Zones, domain)
+	}
+	return result, nil
+}
+
+// readArchive extracts the Corefile and zone files from a gzipped tar. Any
+// other entry, including anything in a subdirectory, is refused, so entry
+// names can't reach outside the configuration directories.
+func readArchive(r io.Reader) (corefile *string, zones map[string]string, err error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("not a gzip archive: %w", err)
+	}
+	defer gz.Close()
+
+	zones = map[string]string{}
+	tr := tar.NewReader(gz)
+	var total int64
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return corefile, zones, nil
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid archive: %w", err)
+		}
+		if hdr.Typeflag == tar.TypeDir {
+			continue
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			return nil, nil, fmt.Errorf("unsupported archive entry %q (only files allowed)", hdr.Name)
+		}
+
+		name := path.Clean(strings.TrimPrefix(hdr.Name, "./"))
+		domain, isZone := strings.CutPrefix(name, zonePrefix)
+		switch {
+		case name == corefileEntry:
+		case isZone && ValidateDomain(domain) == nil:
+		default:
+			return nil, nil, fmt.Errorf("unexpected archive entry %q (only %s and %s<domain> files are restored)", hdr.Name, corefileEntry, zonePrefix)
+		}
+
+		total += hdr.Size
+		if total > maxArchiveSize {
+			return nil, nil, fmt.Errorf("archive exceeds %d MB", maxArchiveSize>>20)
+		}
+		data, err := io.ReadAll(io.LimitReader(tr, hdr.Size))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		content := string(data)
+
+		if name == corefileEntry {
+			if corefile != nil {
+				return nil, nil, fmt.Errorf("archive contains %s twice", corefileEntry)
+			}
+			corefile = &content
+			continue
+		}
+		if _, dup := zones[domain]; dup {
+			return nil, nil, fmt.Errorf("archive contains %s twice", name)
+		}
+		zones[domain] = content
+	}
+}
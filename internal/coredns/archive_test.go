@@ -0,0 +1,171 @@
+package coredns
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type archiveEntry struct {
+	name     string
+	typeflag byte
+	content  string
+	linkname string
+}
+
+// makeArchive builds a gzipped tar of entries.
+func makeArchive(t *testing.T, entries ...archiveEntry) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, e := range entries {
+		typeflag := e.typeflag
+		if typeflag == 0 {
+			typeflag = tar.TypeReg
+		}
+		hdr := &tar.Header{Name: e.name, Typeflag: typeflag, Mode: 0o644, Linkname: e.linkname}
+		if typeflag == tar.TypeReg {
+			hdr.Size = int64(len(e.content))
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(e.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &buf
+}
+
+func TestReadArchive(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []archiveEntry
+		zones   int
+		wantErr string
+	}{
+		{
+			name:    "Corefile and zone",
+			entries: []archiveEntry{{name: "Corefile", content: ". {\n}\n"}, {name: "./db.example.com", content: "zone\n"}},
+			zones:   1,
+		},
+		{
+			name:    "parent directory",
+			entries: []archiveEntry{{name: "../db.example.com", content: "zone\n"}},
+			wantErr: `unexpected archive entry "../db.example.com"`,
+		},
+		{
+			name:    "subdirectory",
+			entries: []archiveEntry{{name: "sub/db.example.com", content: "zone\n"}},
+			wantErr: `unexpected archive entry "sub/db.example.com"`,
+		},
+		{
+			name:    "symlink",
+			entries: []archiveEntry{{name: "db.example.com", typeflag: tar.TypeSymlink, linkname: "/etc/passwd"}},
+			wantErr: `unsupported archive entry "db.example.com"`,
+		},
+		{
+			name:    "hard link",
+			entries: []archiveEntry{{name: "Corefile", typeflag: tar.TypeLink, linkname: "/etc/passwd"}},
+			wantErr: `unsupported archive entry "Corefile"`,
+		},
+		{
+			name:    "duplicate zone",
+			entries: []archiveEntry{{name: "db.example.com", content: "a\n"}, {name: "./db.example.com", content: "b\n"}},
+			wantErr: "archive contains db.example.com twice",
+		},
+		{
+			name:    "duplicate Corefile",
+			entries: []archiveEntry{{name: "Corefile", content: "a\n"}, {name: "Corefile", content: "b\n"}},
+			wantErr: "archive contains Corefile twice",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, zones, err := readArchive(makeArchive(t, tt.entries...))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("readArchive() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readArchive() error = %v", err)
+			}
+			if len(zones) != tt.zones {
+				t.Errorf("got %d zones, want %d", len(zones), tt.zones)
+			}
+		})
+	}
+}
+
+func TestRestoreArchiveRollback(t *testing.T) {
+	zm := newTestZone(t, "example.com", ZoneOptions{})
+	for _, domain := range []string{"new.example", "zz.example"} {
+		if err := zm.Create(domain); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zm.AddRecord("example.com", Record{Name: "www", Type: TypeA, Value: "192.0.2.1"}); err != nil {
+		t.Fatal(err)
+	}
+	raw := map[string]string{}
+	for _, domain := range []string{"example.com", "new.example", "zz.example"} {
+		content, err := zm.ReadRaw(domain)
+		if err != nil {
+			t.Fatal(err)
+		}
+		raw[domain] = content
+	}
+
+	// new.example doesn't exist before the restore, and zz.example can't
+	// be written, so the restore fails after writing the other two
+	if err := zm.Delete("new.example"); err != nil {
+		t.Fatal(err)
+	}
+	if err := zm.Delete("zz.example"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(zm.filename("zz.example"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	corefilePath := filepath.Join(t.TempDir(), "Corefile")
+	const corefile = "example.com {\n    file db.example.com\n}\n"
+	if err := os.WriteFile(corefilePath, []byte(corefile), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cf := NewCorefileManager(corefilePath, nil)
+
+	archive := makeArchive(t,
+		archiveEntry{name: "Corefile", content: "example.org {\n    log\n}\n"},
+		archiveEntry{name: "db.example.com", content: strings.Replace(raw["example.com"], "192.0.2.1", "192.0.2.99", 1)},
+		archiveEntry{name: "db.new.example", content: raw["new.example"]},
+		archiveEntry{name: "db.zz.example", content: raw["zz.example"]},
+	)
+	_, err := RestoreArchive(archive, cf, zm)
+	if err == nil || !strings.Contains(err.Error(), "nothing was restored") {
+		t.Fatalf("RestoreArchive() error = %v, want a rolled back failure", err)
+	}
+
+	if got, _ := cf.Read(); got != corefile {
+		t.Errorf("Corefile after rollback = %q, want %q", got, corefile)
+	}
+	if got, _ := zm.ReadRaw("example.com"); !strings.Contains(got, "192.0.2.1") || strings.Contains(got, "192.0.2.99") {
+		t.Errorf("example.com not rolled back:\n%s", got)
+	}
+	if zm.Exists("new.example") {
+		t.Error("zone created by the failed restore was left behind")
+	}
+}
@@ -0,0 +1,138 @@
+package coredns
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// backupTimeFormat names backups by when they were taken. Parsing accepts it
+// without the milliseconds too, as in backups made by earlier versions.
+const backupTimeFormat = "20060102-150405.000"
+
+// Backups keeps the previous versions of files in a directory, as
+// <name>.<timestamp>, pruning all but the newest keep per file. A nil
+// *Backups, or one with keep 0, saves nothing.
+type Backups struct {
+	dir  string
+	keep int
+}
+
+// Backup is one saved version of a file.
+type Backup struct {
+	ID   string // the timestamp suffix, used to read the backup back
+	Time time.Time
+	Size int64
+}
+
+func NewBackups(dir string, keep int) *Backups {
+	return &Backups{dir: dir, keep: keep}
+}
+
+// Enabled reports whether Save keeps anything.
+func (b *Backups) Enabled() bool {
+	return b != nil && b.dir != "" && b.keep > 0
+}
+
+// Dir returns the backup directory.
+func (b *Backups) Dir() string {
+	if b == nil {
+		return ""
+	}
+	return b.dir
+}
+
+// Save stores content as the newest backup of name and prunes old ones.
+func (b *Backups) Save(name, content string) error {
+	if !b.Enabled() {
+		return nil
+	}
+	_, err := b.save(name, content, b.keep)
+	return err
+}
+
+// Take stores content as the newest backup of name even when keep is 0, in
+// which case only this backup is kept, and returns its ID.
+func (b *Backups) Take(name, content string) (string, error) {
+	if b == nil || b.dir == "" {
+		return "", fmt.Errorf("no backup directory is configured")
+	}
+	return b.save(name, content, max(b.keep, 1))
+}
+
+func (b *Backups) save(name, content string, keep int) (string, error) {
+	if err := os.MkdirAll(b.dir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	id := time.Now().Format(backupTimeFormat)
+	if err := atomicWrite(filepath.Join(b.dir, name+"."+id), content); err != nil {
+		return "", fmt.Errorf("failed to write backup: %w", err)
+	}
+
+	backups, err := b.List(name)
+	if err != nil {
+		return "", err
+	}
+	for _, old := range backups[min(keep, len(backups)):] {
+		os.Remove(filepath.Join(b.dir, name+"."+old.ID))
+	}
+	return id, nil
+}
+
+// List returns the backups of name, newest first.
+func (b *Backups) List(name string) ([]Backup, error) {
+	if b == nil || b.dir == "" {
+		return nil, nil
+	}
+	entries, err := os.ReadDir(b.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list backups: %w", err)
+	}
+
+	var backups []Backup
+	for _, e := range entries {
+		id, ok := strings.CutPrefix(e.Name(), name+".")
+		if !ok || e.IsDir() {
+			continue
+		}
+		t, ok := parseBackupID(id)
+		if !ok {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		backups = append(backups, Backup{ID: id, Time: t, Size: info.Size()})
+	}
+	sort.Slice(backups, func(i, j int) bool { return backups[i].Time.After(backups[j].Time) })
+	return backups, nil
+}
+
+// Read returns the content of the backup of name with the given ID.
+func (b *Backups) Read(name, id string) (string, error) {
+	if _, ok := parseBackupID(id); !ok || b == nil || b.dir == "" {
+		return "", fmt.Errorf("no such backup: %s", id)
+	}
+	data, err := os.ReadFile(filepath.Join(b.dir, name+"."+id))
+	if os.IsNotExist(err) {
+		return "", fmt.Errorf("no such backup: %s", id)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read backup: %w", err)
+	}
+	return string(data), nil
+}
+
+// parseBackupID parses a backup timestamp suffix. Anything else, including
+// path separators, is rejected, so IDs from requests are safe to join.
+func parseBackupID(id string) (time.Time, bool) {
+	t, err := time.ParseInLocation("20060102-150405", id, time.Local)
+	return t, err == nil
+}
@@ -0,0 +1,283 @@
+package coredns
+
+import (
+	"fmt"
+	"strings"
+)
+
+// This file holds a small reader for the Caddyfile syntax CoreDNS uses for
+// its Corefile. It follows the tokenizing rules of CoreDNS's caddyfile
+// package and the grammar of its parser, so it accepts what CoreDNS accepts
+// and catches the structural mistakes that make CoreDNS refuse to start:
+// unterminated quotes, unmatched braces, and server blocks without keys.
+// Validate still runs CoreDNS's own parser afterwards; this reader supplies
+// the line-numbered structure and messages.
+
+// ServerBlock is one server block of a Corefile: its keys (zones, with
+// optional scheme and port) and the plugin directives inside it. A
+// top-level import line is kept as a block with only Import set, since the
+// blocks it pulls in are not expanded.
+type ServerBlock struct {
+	Keys       []string
+	Directives []Directive
+	Import     []string
+	Line       int
+}
+
+// Snippet reports whether the block defines a reusable snippet, such as
+// "(common) { ... }", rather than a server.
+func (b ServerBlock) Snippet() bool {
+	return len(b.Keys) == 1 && strings.HasPrefix(b.Keys[0], "(") && strings.HasSuffix(b.Keys[0], ")")
+}
+
+// Directive is a plugin line inside a server block. A nested block
+// ("forward . 1.1.1.1 { ... }") is not broken down further.
+type Directive struct {
+	Name string
+	Args []string
+	Line int
+}
+
+type corefileToken struct {
+	text   string
+	line   int
+	quoted bool
+}
+
+// isOpen and isClose report whether a token is a structural brace, as
+// opposed to a quoted "{" or an environment placeholder like {$ZONE}.
+func (t corefileToken) isOpen() bool  { return !t.quoted && t.text == "{" }
+func (t corefileToken) isClose() bool { return !t.quoted && t.text == "}" }
+
+// tokenizeCorefile splits a Corefile into tokens with their line numbers.
+// Tokens are separated by whitespace; double quotes group a token and may
+// contain escaped quotes; "#" at the start of a token comments out the rest
+// of the line.
+func tokenizeCorefile(content string) ([]corefileToken, error) {
+	var tokens []corefileToken
+	line := 1
+	var cur strings.Builder
+	inToken, quoted, escaped := false, false, false
+	quoteLine := 0
+
+	flush := func(wasQuoted bool) {
+		if inToken {
+			tokens = append(tokens, corefileToken{text: cur.String(), line: line, quoted: wasQuoted})
+		}
+		cur.Reset()
+		inToken = false
+	}
+
+	for i := 0; i < len(content); i++ {
+		ch := content[i]
+		if quoted {
+			switch {
+			case escaped:
+				if ch != '"' && ch != '\\' {
+					cur.WriteByte('\\')
+				}
+				cur.WriteByte(ch)
+				escaped = false
+			case ch == '\\':
+				escaped = true
+			case ch == '"':
+				quoted = false
+				tokens = append(tokens, corefileToken{text: cur.String(), line: quoteLine, quoted: true})
+				cur.Reset()
+				inToken = false
+			default:
+				if ch == '\n' {
+					line++
+				}
+				cur.WriteByte(ch)
+			}
+			continue
+		}
+
+		switch {
+		case ch == '\n':
+			flush(false)
+			line++
+		case ch == ' ' || ch == '\t' || ch == '\r':
+			flush(false)
+		case ch == '#' && !inToken:
+			for i < len(content) && content[i] != '\n' {
+				i++
+			}
+			i-- // let the newline be handled by the loop
+		case ch == '"' && !inToken:
+			quoted, quoteLine = true, line
+			inToken = true
+		default:
+			inToken = true
+			cur.WriteByte(ch)
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("line %d: unterminated quoted string", quoteLine)
+	}
+	flush(false)
+	return tokens, nil
+}
+
+// corefileLines groups tokens by source line. A quoted token spanning lines
+// belongs to the line it starts on.
+func corefileLines(tokens []corefileToken) [][]corefileToken {
+	var lines [][]corefileToken
+	for i, t := range tokens {
+		if i == 0 || t.line != tokens[i-1].line {
+			lines = append(lines, nil)
+		}
+		lines[len(lines)-1] = append(lines[len(lines)-1], t)
+	}
+	return lines
+}
+
+// endLine returns the line a token ends on; a quoted token may span lines.
+func (t corefileToken) endLine() int {
+	return t.line + strings.Count(t.text, "\n")
+}
+
+// newLine reports whether tokens[i] starts a line, as caddyfile's parser
+// decides it: it is on a later line than the end of the token before it.
+func newLine(tokens []corefileToken, i int) bool {
+	return i == 0 || tokens[i].line > tokens[i-1].endLine()
+}
+
+// parseCorefile reads the server blocks of a Corefile. Like CoreDNS's
+// parser it works on the token stream rather than on lines: keys end at a
+// "{" or the end of their line, the "{" may be on a line of its own, and
+// another block may start right after a "}". A block without braces runs
+// to the end of the file.
+func parseCorefile(content string) ([]ServerBlock, error) {
+	tokens, err := tokenizeCorefile(content)
+	if err != nil {
+		return nil, err
+	}
+
+	var blocks []ServerBlock
+	for i := 0; i < len(tokens); {
+		first := tokens[i]
+		switch {
+		case first.isClose():
+			return nil, fmt.Errorf("line %d: unexpected '}' outside a server block", first.line)
+		case first.isOpen():
+			return nil, fmt.Errorf("line %d: '{' without server block keys (a zone such as example.com or .)", first.line)
+		case first.text == "import" && !first.quoted && newLine(tokens, i):
+			// A top-level import pulls in whole server blocks
+			block := ServerBlock{Line: first.line}
+			for i++; i < len(tokens) && !newLine(tokens, i); i++ {
+				block.Import = append(block.Import, tokens[i].text)
+			}
+			if len(block.Import) == 0 {
+				return nil, fmt.Errorf("line %d: import needs a file or snippet name", first.line)
+			}
+			blocks = append(blocks, block)
+			continue
+		}
+
+		// Keys run until a "{" or the end of the line, or over several
+		// lines when a key ends with a comma
+		block := ServerBlock{Line: first.line}
+		expectingAnother := false
+		for i < len(tokens) {
+			t := tokens[i]
+			if t.isOpen() {
+				if expectingAnother {
+					return nil, fmt.Errorf("line %d: expected another server block key after ','", t.line)
+				}
+				break
+			}
+			if t.isClose() {
+				return nil, fmt.Errorf("line %d: unexpected '}' in server block keys", t.line)
+			}
+			expectingAnother = strings.HasSuffix(t.text, ",")
+			for _, key := range strings.Split(t.text, ",") {
+				if key != "" {
+					block.Keys = append(block.Keys, key)
+				}
+			}
+			i++
+			if i == len(tokens) && expectingAnother {
+				return nil, fmt.Errorf("line %d: expected another server block key after ','", t.line)
+			}
+			if !expectingAnother && i < len(tokens) && newLine(tokens, i) {
+				break
+			}
+		}
+		if len(block.Keys) == 0 {
+			return nil, fmt.Errorf("line %d: server block has no keys", block.Line)
+		}
+
+		// Caddy allows a server block without braces; the rest of the
+		// file is its body
+		braced := i < len(tokens) && tokens[i].isOpen()
+		if braced {
+			i++
+		}
+		dirs, next, err := parseCorefileBody(tokens, i, braced)
+		if err != nil {
+			return nil, err
+		}
+		block.Directives, i = dirs, next
+		blocks = append(blocks, block)
+	}
+	return blocks, nil
+}
+
+// parseCorefileBody reads directives starting at tokens[i]. With braced
+// set, the body must end with a "}", and the index after it is returned;
+// otherwise it runs to the end of the input.
+func parseCorefileBody(tokens []corefileToken, i int, braced bool) ([]Directive, int, error) {
+	var dirs []Directive
+	openLine := 0
+	if braced && i > 0 {
+		openLine = tokens[i-1].line
+	}
+
+	for i < len(tokens) {
+		first := tokens[i]
+		if first.isClose() {
+			if !braced {
+				return nil, 0, fmt.Errorf("line %d: unexpected '}' with no matching '{'", first.line)
+			}
+			return dirs, i + 1, nil
+		}
+		if first.isOpen() {
+			return nil, 0, fmt.Errorf("line %d: '{' without a directive before it", first.line)
+		}
+
+		// Arguments run to the end of the line. A "{", even at the start
+		// of the next line, opens a block of plugin options that ends at
+		// its matching "}"; only its structure is checked.
+		dir := Directive{Name: first.text, Line: first.line}
+		nesting := 0
+		for i++; i < len(tokens); i++ {
+			t := tokens[i]
+			if t.isOpen() {
+				nesting++
+				continue
+			}
+			if nesting == 0 && newLine(tokens, i) {
+				break
+			}
+			switch {
+			case t.isClose() && nesting == 0:
+				return nil, 0, fmt.Errorf("line %d: unexpected '}' with no matching '{'", t.line)
+			case t.isClose():
+				nesting--
+			case nesting == 0:
+				dir.Args = append(dir.Args, t.text)
+			}
+		}
+		if nesting > 0 {
+			return nil, 0, fmt.Errorf("line %d: '{' of %s is never closed", first.line, dir.Name)
+		}
+		dirs = append(dirs, dir)
+	}
+
+	if braced {
+		return nil, 0, fmt.Errorf("line %d: '{' is never closed", openLine)
+	}
+	return dirs, i, nil
+}
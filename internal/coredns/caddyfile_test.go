@@ -0,0 +1,183 @@
+package coredns
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseCorefile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		keys    [][]string // keys of each block, when parsing succeeds
+		dirs    []string   // directive names of the first block
+		wantErr string
+	}{
+		{
+			name:    "server block",
+			content: "example.com {\n    file /zones/db.example.com\n    log\n}\n",
+			keys:    [][]string{{"example.com"}},
+			dirs:    []string{"file", "log"},
+		},
+		{
+			name:    "unterminated quote",
+			content: "example.com {\n    rewrite name \"foo example.com\n}\n",
+			wantErr: "line 2: unterminated quoted string",
+		},
+		{
+			name:    "quoted braces are not structure",
+			content: "example.com {\n    template IN TXT {\n        answer \"{{ .Name }} 60 IN TXT \\\"}\\\"\"\n    }\n}\n",
+			keys:    [][]string{{"example.com"}},
+			dirs:    []string{"template"},
+		},
+		{
+			name:    "stray closing brace",
+			content: "example.com {\n    log\n}\n}\n",
+			wantErr: "line 4: unexpected '}' outside a server block",
+		},
+		{
+			name:    "closing brace inside a directive",
+			content: "example.com {\n    log }\n}\n",
+			wantErr: "line 2: unexpected '}' with no matching '{'",
+		},
+		{
+			name:    "directive on the line of the opening brace",
+			content: "example.com { log\n}\n",
+			keys:    [][]string{{"example.com"}},
+			dirs:    []string{"log"},
+		},
+		{
+			name:    "opening brace on its own line",
+			content: "example.com\n{\n log\n}\n",
+			keys:    [][]string{{"example.com"}},
+			dirs:    []string{"log"},
+		},
+		{
+			name:    "keys right after a closing brace",
+			content: "example.com {\n log\n} example.org {\n log\n}\n",
+			keys:    [][]string{{"example.com"}, {"example.org"}},
+			dirs:    []string{"log"},
+		},
+		{
+			name:    "plugin options opened on the next line",
+			content: "example.com {\n    forward . 1.1.1.1\n    {\n        max_fails 2\n    }\n    log\n}\n",
+			keys:    [][]string{{"example.com"}},
+			dirs:    []string{"forward", "log"},
+		},
+		{
+			name:    "keyless block",
+			content: "{\n    log\n}\n",
+			wantErr: "line 1: '{' without server block keys",
+		},
+		{
+			name:    "unclosed block",
+			content: "example.com {\n    log\n",
+			wantErr: "line 1: '{' is never closed",
+		},
+		{
+			name:    "unbraced single block",
+			content: "example.com\nfile /zones/db.example.com\nlog\n",
+			keys:    [][]string{{"example.com"}},
+			dirs:    []string{"file", "log"},
+		},
+		{
+			name:    "unbraced block after another block",
+			content: "example.com {\n    log\n}\nexample.org\nlog\n",
+			keys:    [][]string{{"example.com"}, {"example.org"}},
+			dirs:    []string{"log"},
+		},
+		{
+			name:    "dangling comma in keys",
+			content: "example.com, {\n    log\n}\n",
+			wantErr: "line 1: expected another server block key after ','",
+		},
+		{
+			name:    "keys over several lines",
+			content: "example.com,\nexample.org {\n    log\n}\n",
+			keys:    [][]string{{"example.com", "example.org"}},
+			dirs:    []string{"log"},
+		},
+		{
+			name:    "environment placeholders",
+			content: "{$ZONE}:53 {\n    forward . {$UPSTREAM}\n}\n",
+			keys:    [][]string{{"{$ZONE}:53"}},
+			dirs:    []string{"forward"},
+		},
+		{
+			name:    "snippet and import",
+			content: "(common) {\n    log\n}\nimport zones/*.conf\nexample.com {\n    import common\n}\n",
+			keys:    [][]string{{"(common)"}, nil, {"example.com"}},
+			dirs:    []string{"log"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			blocks, err := parseCorefile(tt.content)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseCorefile() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseCorefile() error = %v", err)
+			}
+			if len(blocks) != len(tt.keys) {
+				t.Fatalf("got %d blocks, want %d", len(blocks), len(tt.keys))
+			}
+			for i, b := range blocks {
+				if !slices.Equal(b.Keys, tt.keys[i]) {
+					t.Errorf("block %d keys = %q, want %q", i, b.Keys, tt.keys[i])
+				}
+			}
+			var names []string
+			for _, d := range blocks[0].Directives {
+				names = append(names, d.Name)
+			}
+			if !slices.Equal(names, tt.dirs) {
+				t.Errorf("directives = %q, want %q", names, tt.dirs)
+			}
+		})
+	}
+}
+
+func TestCorefileValidate(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "extra.conf"), []byte("example.org {\n    log\n}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	m := NewCorefileManager(filepath.Join(dir, "Corefile"), nil)
+
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{"valid", "example.com {\n    log\n}\n", ""},
+		{"empty", "  \n", "Corefile cannot be empty"},
+		{"unbalanced braces", "example.com {\n    log\n", "unbalanced braces"},
+		{"environment placeholder", "example.com {\n    forward . {$UPSTREAM}\n}\n", ""},
+		{"brace on its own line", "example.com\n{\n log\n}\n", ""},
+		{"keys right after a closing brace", "example.com {\n log\n} example.org {\n log\n}\n", ""},
+		{"existing import", "import extra.conf\n", ""},
+		// Caught by CoreDNS's parser rather than the structural check
+		{"missing import", "example.com {\n    import missing.conf\n}\n", "File to import not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := m.Validate(tt.content)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Validate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package coredns
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// Clone creates zone dst from a copy of zone src. $ORIGIN, absolute owner
+// names, the SOA host and mailbox, and NS hosts under src are moved under
+// dst, and the serial restarts as for a new zone. Other record data is copied
+// unchanged; relative names follow the new origin on their own. Clone fails
+// if dst already exists.
+func (m *ZoneManager) Clone(src, dst string) error {
+	if err := ValidateDomain(src); err != nil {
+		return fmt.Errorf("source: %w", err)
+	}
+	if err := ValidateDomain(dst); err != nil {
+		return fmt.Errorf("destination: %w", err)
+	}
+	if strings.EqualFold(src, dst) {
+		return fmt.Errorf("source and destination are the same zone")
+	}
+	unlock, err := m.lock(dst)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if m.Exists(dst) {
+		return fmt.Errorf("zone file already exists: %s", dst)
+	}
+	raw, err := os.ReadFile(m.filename(src))
+	if err != nil {
+		return err
+	}
+
+	content := cloneZoneContent(string(raw), dns.Fqdn(src), dns.Fqdn(dst))
+	serial := m.initialSerial()
+	content = replaceSOASerial(content, func(string) string { return serial })
+	if err := m.Validate(dst, content); err != nil {
+		return fmt.Errorf("cloned zone does not validate: %w", err)
+	}
+	return m.writeFile(m.filename(dst), content)
+}
+
+// cloneZoneContent rewrites the names that tie a zone file to its origin.
+func cloneZoneContent(content, srcOrigin, dstOrigin string) string {
+	srcOrigin = strings.ToLower(srcOrigin)
+	move := func(tok string) string {
+		lower := strings.ToLower(tok)
+		if lower == srcOrigin {
+			return dstOrigin
+		}
+		if strings.HasSuffix(lower, "."+srcOrigin) {
+			return tok[:len(tok)-len(srcOrigin)] + dstOrigin
+		}
+		return tok
+	}
+
+	lines := strings.Split(content, "\n")
+	soaStart, soaEnd, _ := findSOABlock(lines)
+	for i, line := range lines {
+		code := stripZoneComment(line)
+		comment := line[len(code):]
+		fields := strings.Fields(code)
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch {
+		case soaStart >= 0 && i >= soaStart && i <= soaEnd,
+			strings.EqualFold(fields[0], "$ORIGIN"),
+			isNSLine(fields):
+			code = mapZoneTokens(code, move)
+		case line[0] != ' ' && line[0] != '\t' && !strings.HasPrefix(fields[0], "$"):
+			code = move(fields[0]) + code[len(fields[0]):]
+		}
+		lines[i] = code + comment
+	}
+	return strings.Join(lines, "\n")
+}
+
+// isNSLine reports whether the fields of a record line hold an NS record.
+func isNSLine(fields []string) bool {
+	for i, f := range fields {
+		if i > 3 || i == len(fields)-1 {
+			break
+		}
+		if strings.EqualFold(f, "NS") {
+			return true
+		}
+	}
+	return false
+}
+
+// mapZoneTokens applies f to every whitespace-separated token in code,
+// keeping the original spacing.
+func mapZoneTokens(code string, f func(string) string) string {
+	var b strings.Builder
+	for i := 0; i < len(code); {
+		if code[i] == ' ' || code[i] == '\t' {
+			b.WriteByte(code[i])
+			i++
+			continue
+		}
+		j := i
+		for j < len(code) && code[j] != ' ' && code[j] != '\t' {
+			j++
+		}
+		b.WriteString(f(code[i:j]))
+		i = j
+	}
+	return b.String()
+}
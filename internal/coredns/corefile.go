@@ -2,17 +2,28 @@ package coredns
 
 import (
 	"fmt"
+	"log"
+	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+
+	"github.com/coredns/caddy/caddyfile"
 )
 
 type CorefileManager struct {
-	path string
+	path    string
+	backups *Backups // previous versions saved before each write; may be nil
+}
+
+func NewCorefileManager(path string, backups *Backups) *CorefileManager {
+	return &CorefileManager{path: path, backups: backups}
 }
 
-func NewCorefileManager(path string) *CorefileManager {
-	return &CorefileManager{path: path}
+// Path returns the location of the Corefile on disk.
+func (m *CorefileManager) Path() string {
+	return m.path
 }
 
 func (m *CorefileManager) Read() (string, error) {
@@ -24,6 +35,153 @@ func (m *CorefileManager) Read() (string, error) {
 }
 
 func (m *CorefileManager) Write(content string) error {
+	return m.writePath(m.path, content)
+}
+
+// ReadFragment reads a file pulled in by an import directive. name must be
+// one of the paths returned by Imports.
+func (m *CorefileManager) ReadFragment(name string) (string, error) {
+	path, err := m.fragmentPath(name)
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	return string(data), nil
+}
+
+// WriteFragment atomically replaces an imported file.
+func (m *CorefileManager) WriteFragment(name, content string) error {
+	path, err := m.fragmentPath(name)
+	if err != nil {
+		return err
+	}
+	return m.writePath(path, content)
+}
+
+func (m *CorefileManager) fragmentPath(name string) (string, error) {
+	fragments, _ := m.Imports()
+	for _, f := range fragments {
+		if f == name {
+			return m.resolveImport(name), nil
+		}
+	}
+	return "", fmt.Errorf("%s is not imported by the Corefile", name)
+}
+
+// Imports follows import directives from the Corefile and returns the
+// imported files, as written relative to the Corefile's directory where
+// possible. Warnings report import cycles and patterns matching nothing.
+func (m *CorefileManager) Imports() (fragments []string, warnings []string) {
+	seen := map[string]bool{}
+	var walk func(path string, stack []string)
+	walk = func(path string, stack []string) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return
+		}
+		content := string(data)
+		snippets := corefileSnippets(content)
+
+		for _, pattern := range importArgs(content) {
+			if snippets[pattern] {
+				continue
+			}
+			// Like Caddy, relative imports resolve against the importing file
+			if !filepath.IsAbs(pattern) {
+				pattern = filepath.Join(filepath.Dir(path), pattern)
+			}
+			matches, err := filepath.Glob(pattern)
+			if err != nil || len(matches) == 0 {
+				warnings = append(warnings, fmt.Sprintf("import %s in %s matches no files", m.displayName(pattern), m.displayName(path)))
+				continue
+			}
+			for _, match := range matches {
+				if slices.Contains(stack, match) || match == m.path {
+					warnings = append(warnings, fmt.Sprintf("import cycle: %s imports %s", m.displayName(path), m.displayName(match)))
+					continue
+				}
+				if seen[match] {
+					continue
+				}
+				seen[match] = true
+				fragments = append(fragments, m.displayName(match))
+				walk(match, append(stack, match))
+			}
+		}
+	}
+	walk(m.path, []string{m.path})
+	return fragments, warnings
+}
+
+// FilePath returns the path of the Corefile for "Corefile" or an empty name,
+// and of an imported fragment, as named by Imports, otherwise.
+func (m *CorefileManager) FilePath(name string) string {
+	if name == "" || name == "Corefile" {
+		return m.path
+	}
+	return m.resolveImport(name)
+}
+
+// resolveImport resolves a fragment name relative to the Corefile directory.
+func (m *CorefileManager) resolveImport(p string) string {
+	if filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(filepath.Dir(m.path), p)
+}
+
+func (m *CorefileManager) displayName(path string) string {
+	if rel, err := filepath.Rel(filepath.Dir(m.path), path); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return path
+}
+
+// importArgs returns the arguments of every import directive.
+func importArgs(content string) []string {
+	tokens, err := tokenizeCorefile(content)
+	if err != nil {
+		return nil
+	}
+	var args []string
+	for _, line := range corefileLines(tokens) {
+		if len(line) >= 2 && !line[0].quoted && line[0].text == "import" {
+			args = append(args, line[1].text)
+		}
+	}
+	return args
+}
+
+// corefileSnippets returns the names of snippets defined as "(name) { ... }",
+// which import can reference instead of a file.
+func corefileSnippets(content string) map[string]bool {
+	snippets := map[string]bool{}
+	blocks, err := parseCorefile(content)
+	if err != nil {
+		return snippets
+	}
+	for _, b := range blocks {
+		if b.Snippet() {
+			snippets[strings.Trim(b.Keys[0], "()")] = true
+		}
+	}
+	return snippets
+}
+
+// backupName names the backups of a file. Fragments are named by their path
+// relative to the Corefile, escaped into one file name, so fragments with
+// the same base name in different directories keep separate backups.
+func (m *CorefileManager) backupName(path string) string {
+	if path == m.path {
+		return filepath.Base(path)
+	}
+	return "fragment." + url.QueryEscape(filepath.ToSlash(m.displayName(path)))
+}
+
+func (m *CorefileManager) writePath(path, content string) error {
 	// Normalize line endings
 	content = strings.ReplaceAll(content, "\r\n", "\n")
 
@@ -32,10 +190,19 @@ func (m *CorefileManager) Write(content string) error {
 		content += "\n"
 	}
 
+	if old, err := os.ReadFile(path); err == nil {
+		if err := m.backups.Save(m.backupName(path), string(old)); err != nil {
+			return err
+		}
+	}
+
 	// Atomic write: write to temp file then rename
-	dir := filepath.Dir(m.path)
+	dir := filepath.Dir(path)
 	tmp, err := os.CreateTemp(dir, ".corefile-*.tmp")
 	if err != nil {
+		if isNotWritable(err) {
+			return fmt.Errorf("Corefile directory is not writable: check the volume mount/permissions of %s", dir)
+		}
 		return fmt.Errorf("failed to create temp file: %w", err)
 	}
 	tmpPath := tmp.Name()
@@ -45,24 +212,44 @@ func (m *CorefileManager) Write(content string) error {
 		os.Remove(tmpPath)
 		return fmt.Errorf("failed to write temp file: %w", err)
 	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to sync temp file: %w", err)
+	}
 	if err := tmp.Close(); err != nil {
 		os.Remove(tmpPath)
 		return fmt.Errorf("failed to close temp file: %w", err)
 	}
 
 	// Preserve original permissions
-	info, err := os.Stat(m.path)
+	info, err := os.Stat(path)
 	if err == nil {
 		os.Chmod(tmpPath, info.Mode())
 	}
 
-	if err := os.Rename(tmpPath, m.path); err != nil {
+	if err := os.Rename(tmpPath, path); err != nil {
 		os.Remove(tmpPath)
 		return fmt.Errorf("failed to rename temp file: %w", err)
 	}
+	if err := syncDir(dir); err != nil {
+		log.Printf("wrote %s, but %v", path, err)
+	}
 	return nil
 }
 
+// Parse reads the server blocks of the Corefile. Imports are listed but
+// not expanded.
+func (m *CorefileManager) Parse() ([]ServerBlock, error) {
+	content, err := m.Read()
+	if err != nil {
+		return nil, err
+	}
+	return parseCorefile(content)
+}
+
+// Validate checks that content is a well-formed Corefile: a brace count as a
+// quick check, then a full read of its server blocks.
 func (m *CorefileManager) Validate(content string) error {
 	content = strings.TrimSpace(content)
 	if content == "" {
@@ -76,5 +263,132 @@ func (m *CorefileManager) Validate(content string) error {
 		return fmt.Errorf("unbalanced braces: %d opening, %d closing", open, close)
 	}
 
-	return nil
+	if _, err := parseCorefile(content); err != nil {
+		return err
+	}
+	// The reader above gives clearer messages for common mistakes; CoreDNS's
+	// own parser has the final word, including on the files imported
+	_, err := caddyfile.Parse(m.path, strings.NewReader(content), nil)
+	return err
+}
+
+// ValidateFragment checks an imported Corefile fragment. A fragment may
+// hold whole server blocks or bare directives meant to be imported inside
+// one, so only its tokens and brace structure are checked.
+func (m *CorefileManager) ValidateFragment(content string) error {
+	if strings.TrimSpace(content) == "" {
+		return fmt.Errorf("fragment cannot be empty")
+	}
+	tokens, err := tokenizeCorefile(content)
+	if err != nil {
+		return err
+	}
+	_, _, err = parseCorefileBody(tokens, 0, false)
+	return err
+}
+
+// CorefileLine is one line of a Corefile, annotated with the zone file it
+// references if it is a `file` directive.
+type CorefileLine struct {
+	Text    string
+	Path    string // zone file named by the `file` directive on the line
+	Domain  string // managed zone referenced by the line, if any
+	Managed bool   // the referenced file lives in the zone directory as db.<domain>
+	Missing bool   // the referenced zone file does not exist
+}
+
+// Annotate splits the Corefile into lines and resolves each `file`
+// directive against the zone directory.
+func (m *CorefileManager) Annotate(content string, zones *ZoneManager) []CorefileLine {
+	refs := map[int]string{}
+	for _, ref := range fileDirectives(content) {
+		refs[ref.line] = ref.path
+	}
+	lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+	result := make([]CorefileLine, 0, len(lines))
+	for i, text := range lines {
+		line := CorefileLine{Text: text}
+		if path, ok := refs[i+1]; ok {
+			line.Path = path
+			line.Domain, line.Managed, line.Missing = zones.resolveFileDirective(path, filepath.Dir(m.path))
+		}
+		result = append(result, line)
+	}
+	return result
+}
+
+// EnabledPlugins lists the plugin directives used in the server blocks and
+// snippets of the Corefile and the files it imports. Files imported at the
+// top level are read as server blocks, files imported inside a block as
+// bare directives.
+func (m *CorefileManager) EnabledPlugins() ([]string, error) {
+	content, err := m.Read()
+	if err != nil {
+		return nil, err
+	}
+	blocks, err := parseCorefile(content)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	visited := map[string]bool{m.path: true}
+	var addBlocks func(from string, blocks []ServerBlock)
+	var addDirectives func(from string, dirs []Directive)
+	// follow reads the files matched by an import pattern, relative to the
+	// importing file as in Caddy; snippet names match no file
+	follow := func(from string, args []string, body bool) {
+		if len(args) == 0 {
+			return
+		}
+		pattern := args[0]
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(filepath.Dir(from), pattern)
+		}
+		matches, _ := filepath.Glob(pattern)
+		for _, match := range matches {
+			if visited[match] {
+				continue
+			}
+			visited[match] = true
+			data, err := os.ReadFile(match)
+			if err != nil {
+				continue
+			}
+			if body {
+				tokens, err := tokenizeCorefile(string(data))
+				if err != nil {
+					continue
+				}
+				if dirs, _, err := parseCorefileBody(tokens, 0, false); err == nil {
+					addDirectives(match, dirs)
+				}
+			} else if blocks, err := parseCorefile(string(data)); err == nil {
+				addBlocks(match, blocks)
+			}
+		}
+	}
+	addDirectives = func(from string, dirs []Directive) {
+		for _, d := range dirs {
+			if d.Name == "import" {
+				follow(from, d.Args, true)
+				continue
+			}
+			seen[d.Name] = true
+		}
+	}
+	addBlocks = func(from string, blocks []ServerBlock) {
+		for _, b := range blocks {
+			follow(from, b.Import, false)
+			addDirectives(from, b.Directives)
+		}
+	}
+	addBlocks(m.path, blocks)
+
+	plugins := make([]string, 0, len(seen))
+	for p := range seen {
+		plugins = append(plugins, p)
+	}
+	slices.Sort(plugins)
+	return plugins, nil
 }
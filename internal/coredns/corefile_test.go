@@ -0,0 +1,104 @@
+package coredns
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestEnabledPlugins(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"Corefile": "(common) {\n    errors\n}\n" +
+			"import servers.conf\n" +
+			"example.com {\n    import common\n    import extra.conf\n    file db.example.com\n    # cache\n    forward . 1.1.1.1 {\n        max_fails 2\n    }\n}\n",
+		"servers.conf": "example.org {\n    log\n}\n",
+		"extra.conf":   "health\nready\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m := NewCorefileManager(filepath.Join(dir, "Corefile"), nil)
+
+	got, err := m.EnabledPlugins()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"errors", "file", "forward", "health", "log", "ready"}
+	if !slices.Equal(got, want) {
+		t.Errorf("EnabledPlugins() = %q, want %q", got, want)
+	}
+}
+
+func TestAnnotate(t *testing.T) {
+	zones := newTestZone(t, "example.com", ZoneOptions{})
+	m := NewCorefileManager(filepath.Join(t.TempDir(), "Corefile"), nil)
+	content := "example.com {\n" +
+		"    # file /zones/db.example.org\n" +
+		"    file \"/zones/db.example.com\" {\n" +
+		"        reload 30s\n" +
+		"    }\n" +
+		"}\n"
+
+	lines := m.Annotate(content, zones)
+	if len(lines) != 7 {
+		t.Fatalf("got %d lines, want 7", len(lines))
+	}
+	for i, line := range lines {
+		if i == 2 {
+			if line.Path != "/zones/db.example.com" || line.Domain != "example.com" || !line.Managed || line.Missing {
+				t.Errorf("line 3 = %+v, want a managed reference to example.com", line)
+			}
+			continue
+		}
+		if line.Domain != "" {
+			t.Errorf("line %d (%q) references %s", i+1, line.Text, line.Domain)
+		}
+	}
+}
+
+func TestFragmentBackups(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"Corefile":       "import a/servers.conf\nimport b/servers.conf\n",
+		"a/servers.conf": "example.com {\n    log\n}\n",
+		"b/servers.conf": "example.org {\n    log\n}\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	backups := NewBackups(filepath.Join(dir, "backups"), 5)
+	m := NewCorefileManager(filepath.Join(dir, "Corefile"), backups)
+
+	for _, name := range []string{"a/servers.conf", "b/servers.conf"} {
+		if err := m.WriteFragment(name, "changed\n"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"a/servers.conf", "b/servers.conf"} {
+		key := m.backupName(filepath.Join(dir, name))
+		list, err := backups.List(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(list) != 1 {
+			t.Fatalf("%s has %d backups, want 1", name, len(list))
+		}
+		got, err := backups.Read(key, list[0].ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != files[name] {
+			t.Errorf("backup of %s = %q, want %q", name, got, files[name])
+		}
+	}
+}
@@ -9,16 +9,32 @@ import (
 )
 
 func GenerateDiff(filename, original, modified string) string {
+	return fmt.Sprint(unifiedDiff(filename, original, modified))
+}
+
+// GenerateDiffStats returns the same diff as GenerateDiff along with the
+// number of lines it adds and removes, for a quick sense of its size.
+func GenerateDiffStats(filename, original, modified string) (added, removed int, diff string) {
+	unified := unifiedDiff(filename, original, modified)
+	for _, hunk := range unified.Hunks {
+		for _, line := range hunk.Lines {
+			switch line.Kind {
+			case gotextdiff.Insert:
+				added++
+			case gotextdiff.Delete:
+				removed++
+			}
+		}
+	}
+	return added, removed, fmt.Sprint(unified)
+}
+
+func unifiedDiff(filename, original, modified string) gotextdiff.Unified {
 	edits := myers.ComputeEdits(span.URIFromPath(filename), original, modified)
-	unified := gotextdiff.ToUnified(
+	return gotextdiff.ToUnified(
 		fmt.Sprintf("a/%s", filename),
 		fmt.Sprintf("b/%s", filename),
 		original,
 		edits,
 	)
-	result := fmt.Sprint(unified)
-	if result == "" {
-		return ""
-	}
-	return result
 }
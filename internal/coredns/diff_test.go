@@ -0,0 +1,28 @@
+package coredns
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerateDiffStats(t *testing.T) {
+	original := "@ IN A 192.0.2.1\n-- IN TXT \"dashes\"\nwww IN A 192.0.2.2\nold IN A 192.0.2.3\n"
+	modified := "@ IN A 192.0.2.1\nwww IN A 192.0.2.20\nnew IN A 192.0.2.4\nmail IN A 192.0.2.5\n"
+
+	added, removed, diff := GenerateDiffStats("db.example.com", original, modified)
+	if added != 3 || removed != 3 {
+		t.Errorf("GenerateDiffStats() = +%d -%d, want +3 -3", added, removed)
+	}
+	if want := GenerateDiff("db.example.com", original, modified); diff != want {
+		t.Errorf("diff differs from GenerateDiff:\n%s\nwant:\n%s", diff, want)
+	}
+	// A removed line that itself starts with "--" is counted once and not
+	// mistaken for the file header
+	if !strings.Contains(diff, "\n--- IN TXT \"dashes\"\n") {
+		t.Errorf("diff lacks the removed \"--\" line:\n%s", diff)
+	}
+
+	if added, removed, diff := GenerateDiffStats("db.example.com", original, original); added != 0 || removed != 0 || diff != "" {
+		t.Errorf("GenerateDiffStats() on equal content = +%d -%d %q, want nothing", added, removed, diff)
+	}
+}
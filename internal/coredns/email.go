@@ -0,0 +1,206 @@
+package coredns
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/mail"
+	"strconv"
+	"strings"
+)
+
+// DMARCPolicy holds the tags of a DMARC record. Empty fields are omitted.
+type DMARCPolicy struct {
+	Policy          string // p: none, quarantine, reject
+	SubdomainPolicy string // sp
+	Percent         string // pct: 0-100
+	RUA             string // aggregate report URIs, comma-separated
+	RUF             string // forensic report URIs, comma-separated
+	ADKIM           string // r or s
+	ASPF            string // r or s
+}
+
+// BuildSPF assembles and validates an SPF record value from its mechanisms
+// and the terminating "all" qualifier (e.g. "-all", "~all").
+func BuildSPF(mechanisms []string, all string) (string, error) {
+	parts := []string{"v=spf1"}
+	for _, mech := range mechanisms {
+		mech = strings.TrimSpace(mech)
+		if mech == "" {
+			continue
+		}
+		if err := validateSPFMechanism(mech); err != nil {
+			return "", err
+		}
+		parts = append(parts, mech)
+	}
+
+	switch all {
+	case "":
+	case "-all", "~all", "?all", "+all":
+		parts = append(parts, all)
+	default:
+		return "", fmt.Errorf("invalid SPF all qualifier %q (allowed: -all, ~all, ?all, +all)", all)
+	}
+
+	if len(parts) == 1 {
+		return "", fmt.Errorf("SPF record needs at least one mechanism")
+	}
+	return strings.Join(parts, " "), nil
+}
+
+func validateSPFMechanism(mech string) error {
+	if strings.HasPrefix(mech, "redirect=") || strings.HasPrefix(mech, "exp=") {
+		_, domain, _ := strings.Cut(mech, "=")
+		return validateSPFDomain(mech, domain)
+	}
+
+	term := strings.TrimLeft(mech, "+-~?")
+	if len(mech)-len(term) > 1 {
+		return fmt.Errorf("invalid SPF mechanism %q: multiple qualifiers", mech)
+	}
+
+	name, arg, hasArg := strings.Cut(term, ":")
+	if !hasArg {
+		// "a/24" and "mx/24" carry a CIDR length without a domain
+		name, arg, hasArg = strings.Cut(term, "/")
+		if hasArg {
+			arg = "/" + arg
+		}
+	}
+
+	switch strings.ToLower(name) {
+	case "all":
+		return fmt.Errorf("use the all qualifier field instead of %q", mech)
+	case "ip4":
+		ip, _, err := net.ParseCIDR(arg)
+		if err != nil {
+			ip = net.ParseIP(arg)
+		}
+		if ip == nil || ip.To4() == nil {
+			return fmt.Errorf("invalid SPF mechanism %q: not an IPv4 address or network", mech)
+		}
+	case "ip6":
+		ip, _, err := net.ParseCIDR(arg)
+		if err != nil {
+			ip = net.ParseIP(arg)
+		}
+		if ip == nil || ip.To4() != nil {
+			return fmt.Errorf("invalid SPF mechanism %q: not an IPv6 address or network", mech)
+		}
+	case "a", "mx", "ptr":
+		if hasArg && !strings.HasPrefix(arg, "/") {
+			domain, _, _ := strings.Cut(arg, "/")
+			return validateSPFDomain(mech, domain)
+		}
+	case "include", "exists":
+		if !hasArg || arg == "" {
+			return fmt.Errorf("invalid SPF mechanism %q: %s requires a domain", mech, name)
+		}
+		return validateSPFDomain(mech, arg)
+	default:
+		return fmt.Errorf("unknown SPF mechanism %q", mech)
+	}
+	return nil
+}
+
+func validateSPFDomain(mech, domain string) error {
+	// Macros (%{...}) are allowed in domain-specs, so only reject whitespace
+	// and obviously empty values.
+	if domain == "" || strings.ContainsAny(domain, " \t\"") {
+		return fmt.Errorf("invalid SPF mechanism %q: bad domain", mech)
+	}
+	return nil
+}
+
+// BuildDMARC assembles and validates a DMARC record value.
+func BuildDMARC(p DMARCPolicy) (string, error) {
+	if !isDMARCPolicy(p.Policy) {
+		return "", fmt.Errorf("invalid DMARC policy %q (allowed: none, quarantine, reject)", p.Policy)
+	}
+	tags := []string{"v=DMARC1", "p=" + p.Policy}
+
+	if p.SubdomainPolicy != "" {
+		if !isDMARCPolicy(p.SubdomainPolicy) {
+			return "", fmt.Errorf("invalid DMARC subdomain policy %q", p.SubdomainPolicy)
+		}
+		tags = append(tags, "sp="+p.SubdomainPolicy)
+	}
+	if p.Percent != "" {
+		pct, err := strconv.Atoi(p.Percent)
+		if err != nil || pct < 0 || pct > 100 {
+			return "", fmt.Errorf("invalid DMARC pct %q (must be 0-100)", p.Percent)
+		}
+		tags = append(tags, "pct="+p.Percent)
+	}
+	if p.RUA != "" {
+		if err := validateDMARCURIs("rua", p.RUA); err != nil {
+			return "", err
+		}
+		tags = append(tags, "rua="+p.RUA)
+	}
+	if p.RUF != "" {
+		if err := validateDMARCURIs("ruf", p.RUF); err != nil {
+			return "", err
+		}
+		tags = append(tags, "ruf="+p.RUF)
+	}
+	if p.ADKIM != "" {
+		if p.ADKIM != "r" && p.ADKIM != "s" {
+			return "", fmt.Errorf("invalid DMARC adkim %q (allowed: r, s)", p.ADKIM)
+		}
+		tags = append(tags, "adkim="+p.ADKIM)
+	}
+	if p.ASPF != "" {
+		if p.ASPF != "r" && p.ASPF != "s" {
+			return "", fmt.Errorf("invalid DMARC aspf %q (allowed: r, s)", p.ASPF)
+		}
+		tags = append(tags, "aspf="+p.ASPF)
+	}
+
+	return strings.Join(tags, "; "), nil
+}
+
+func isDMARCPolicy(p string) bool {
+	return p == "none" || p == "quarantine" || p == "reject"
+}
+
+func validateDMARCURIs(tag, value string) error {
+	for _, uri := range strings.Split(value, ",") {
+		uri = strings.TrimSpace(uri)
+		addr, ok := strings.CutPrefix(uri, "mailto:")
+		if !ok {
+			return fmt.Errorf("invalid DMARC %s %q: URIs must start with mailto:", tag, uri)
+		}
+		// An optional "!size" suffix limits report size
+		addr, _, _ = strings.Cut(addr, "!")
+		if _, err := mail.ParseAddress(addr); err != nil {
+			return fmt.Errorf("invalid DMARC %s address %q", tag, addr)
+		}
+	}
+	return nil
+}
+
+// BuildDKIM assembles and validates a DKIM key record value.
+func BuildDKIM(keyType, publicKey string) (string, error) {
+	if keyType == "" {
+		keyType = "rsa"
+	}
+	if keyType != "rsa" && keyType != "ed25519" {
+		return "", fmt.Errorf("invalid DKIM key type %q (allowed: rsa, ed25519)", keyType)
+	}
+
+	// Accept keys pasted with PEM armor or line breaks
+	key := strings.TrimSpace(publicKey)
+	key = strings.TrimPrefix(key, "-----BEGIN PUBLIC KEY-----")
+	key = strings.TrimSuffix(key, "-----END PUBLIC KEY-----")
+	key = strings.Join(strings.Fields(key), "")
+	if key == "" {
+		return "", fmt.Errorf("DKIM public key cannot be empty")
+	}
+	if _, err := base64.StdEncoding.DecodeString(key); err != nil {
+		return "", fmt.Errorf("DKIM public key is not valid base64")
+	}
+
+	return fmt.Sprintf("v=DKIM1; k=%s; p=%s", keyType, key), nil
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package coredns
+
+import "time"
+
+// flockFile is a no-op where flock(2) isn't available; writes are then only
+// serialized within this process.
+func flockFile(path string, timeout time.Duration) (func(), error) {
+	return func() {}, nil
+}
@@ -0,0 +1,50 @@
+//go:build unix
+
+package coredns
+
+import (
+	"errors"
+	"os"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// flockFile takes an exclusive advisory lock on path, creating it if needed,
+// and polls until timeout if another process holds it. The holder may
+// remove the lock file before unlocking, so a lock taken on a file that has
+// since been removed or replaced is dropped and taken again on the new one.
+func flockFile(path string, timeout time.Duration) (func(), error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		for {
+			err = unix.Flock(int(f.Fd()), unix.LOCK_EX|unix.LOCK_NB)
+			if err == nil || !errors.Is(err, unix.EWOULDBLOCK) && !errors.Is(err, unix.EINTR) {
+				break
+			}
+			if time.Now().After(deadline) {
+				f.Close()
+				return nil, errLockTimeout
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+
+		held, herr := f.Stat()
+		current, cerr := os.Stat(path)
+		if herr == nil && cerr == nil && os.SameFile(held, current) {
+			return func() {
+				unix.Flock(int(f.Fd()), unix.LOCK_UN)
+				f.Close()
+			}, nil
+		}
+		f.Close()
+	}
+}
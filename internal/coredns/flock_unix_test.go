@@ -0,0 +1,44 @@
+//go:build unix
+
+package coredns
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFlockFileRemovedByHolder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "zone.lock")
+	unlock, err := flockFile(path, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	acquired := make(chan func())
+	go func() {
+		next, err := flockFile(path, 5*time.Second)
+		if err != nil {
+			t.Error(err)
+			next = func() {}
+		}
+		acquired <- next
+	}()
+
+	// Let the waiter open the old file before it is removed
+	time.Sleep(100 * time.Millisecond)
+	os.Remove(path)
+	unlock()
+	next := <-acquired
+	defer next()
+
+	// The waiter must hold the file now at path, not the removed one, or a
+	// third locker would get in alongside it
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("lock file not recreated: %v", err)
+	}
+	if _, err := flockFile(path, 100*time.Millisecond); err != errLockTimeout {
+		t.Errorf("second lock on the recreated file = %v, want %v", err, errLockTimeout)
+	}
+}
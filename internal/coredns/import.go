@@ -0,0 +1,133 @@
+package coredns
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// ImportLineError is a pasted line that could not be imported.
+type ImportLineError struct {
+	Line int
+	Text string
+	Err  error
+}
+
+// ImportError lists the lines ImportRecords skipped. The valid lines in the
+// same block are still added.
+type ImportError struct {
+	Lines []ImportLineError
+}
+
+func (e *ImportError) Error() string {
+	return fmt.Sprintf("%d line(s) could not be imported", len(e.Lines))
+}
+
+// ImportRecords parses a pasted block of zone-file lines, one record per
+// line, relative to the zone origin. Valid records are appended with a
+// single serial bump; lines that don't parse, fall outside the zone, or
+// fail validation are returned in an *ImportError alongside the count of
+// records that were added. If the zone as a whole would no longer
+// validate (for example a CNAME conflict), nothing is written.
+func (m *ZoneManager) ImportRecords(domain, block string) (added int, err error) {
+	if err := ValidateDomain(domain); err != nil {
+		return 0, err
+	}
+	origin := dns.Fqdn(domain)
+
+	var lines []string
+	skipped := &ImportError{}
+	for i, text := range strings.Split(strings.ReplaceAll(block, "\r\n", "\n"), "\n") {
+		text = strings.TrimSpace(text)
+		if text == "" || strings.HasPrefix(text, ";") {
+			continue
+		}
+		line, err := m.importLine(text, origin)
+		if err != nil {
+			skipped.Lines = append(skipped.Lines, ImportLineError{Line: i + 1, Text: text, Err: err})
+			continue
+		}
+		lines = append(lines, line)
+	}
+
+	if len(lines) > 0 {
+		unlock, err := m.lock(domain)
+		if err != nil {
+			return 0, err
+		}
+		defer unlock()
+
+		path := m.filename(domain)
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return 0, err
+		}
+		content := string(raw)
+		if !strings.HasSuffix(content, "\n") {
+			content += "\n"
+		}
+		content += strings.Join(lines, "\n") + "\n"
+		content = m.incrementSOASerial(content)
+		if err := m.Validate(domain, content); err != nil {
+			return 0, err
+		}
+		if err := m.writeFile(path, content); err != nil {
+			return 0, err
+		}
+	}
+
+	if len(skipped.Lines) > 0 {
+		return len(lines), skipped
+	}
+	return len(lines), nil
+}
+
+// importLine parses and validates one pasted record and returns it in the
+// form AddRecord would write.
+func (m *ZoneManager) importLine(text, origin string) (string, error) {
+	if strings.HasPrefix(text, "$") {
+		return "", fmt.Errorf("directives are not supported here")
+	}
+	if strings.Contains(stripZoneComment(text), "(") {
+		return "", fmt.Errorf("multi-line records are not supported; put the record on one line")
+	}
+
+	// A default TTL of 0 marks records without an explicit TTL, so they
+	// are written without one and keep inheriting the zone's default
+	parser := dns.NewZoneParser(strings.NewReader(text), origin, "")
+	parser.SetDefaultTTL(0)
+	rr, ok := parser.Next()
+	if err := parser.Err(); err != nil {
+		// The caller reports the line; drop the parser's position suffix
+		msg, _, _ := strings.Cut(err.Error(), " at line: ")
+		return "", fmt.Errorf("%s", msg)
+	}
+	if !ok {
+		return "", fmt.Errorf("no record found")
+	}
+	if _, more := parser.Next(); more {
+		return "", fmt.Errorf("more than one record on the line")
+	}
+
+	if !dns.IsSubDomain(origin, rr.Header().Name) {
+		return "", fmt.Errorf("%s is outside the zone %s", rr.Header().Name, origin)
+	}
+	rec, ok := recordFromRR(rr, origin)
+	if !ok {
+		return "", fmt.Errorf("unsupported record type %s", dns.TypeToString[rr.Header().Rrtype])
+	}
+	if err := ValidateRecordName(rec.Name, m.opts.StrictNames); err != nil {
+		return "", err
+	}
+	if err := validateRecordValue(rec); err != nil {
+		return "", err
+	}
+	if rec.Type == TypeTXT {
+		// Keep the character-string boundaries and escaping as parsed;
+		// joining them would change the record
+		rec.Value = strings.TrimPrefix(rr.String(), rr.Header().String())
+	}
+	return formatRecord(rec), nil
+}
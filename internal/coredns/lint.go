@@ -0,0 +1,81 @@
+package coredns
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// ZoneVisibility says who a zone is meant to be served to.
+type ZoneVisibility string
+
+const (
+	VisibilityUnknown  ZoneVisibility = ""
+	VisibilityPublic   ZoneVisibility = "public"
+	VisibilityInternal ZoneVisibility = "internal"
+)
+
+// LintWarning is a likely mistake found in a zone's records.
+type LintWarning struct {
+	Record  Record
+	Message string
+}
+
+// cgnatPrefix is the RFC 6598 shared address space, which netip doesn't
+// treat as private but is just as unreachable from the internet.
+var cgnatPrefix = netip.MustParsePrefix("100.64.0.0/10")
+
+// VisibilityFor returns the visibility of domain given the configured public
+// and internal zone lists. An entry also covers its subdomains.
+func VisibilityFor(domain string, public, internal []string) ZoneVisibility {
+	matches := func(list []string) bool {
+		for _, z := range list {
+			if strings.EqualFold(domain, z) || strings.HasSuffix(strings.ToLower(domain), "."+strings.ToLower(z)) {
+				return true
+			}
+		}
+		return false
+	}
+	switch {
+	case matches(public):
+		return VisibilityPublic
+	case matches(internal):
+		return VisibilityInternal
+	}
+	return VisibilityUnknown
+}
+
+// LintAddresses flags A and AAAA records whose addresses don't fit the zone:
+// private, loopback, or link-local addresses in a public zone leak internal
+// topology, and public addresses in an internal zone are usually a copy-paste
+// from the public view.
+func LintAddresses(records []Record, visibility ZoneVisibility) []LintWarning {
+	if visibility == VisibilityUnknown {
+		return nil
+	}
+
+	var warnings []LintWarning
+	for _, rec := range records {
+		if rec.Type != TypeA && rec.Type != TypeAAAA {
+			continue
+		}
+		addr, err := netip.ParseAddr(rec.Value)
+		if err != nil {
+			continue
+		}
+		nonPublic := isNonPublicAddr(addr)
+		switch {
+		case visibility == VisibilityPublic && nonPublic:
+			warnings = append(warnings, LintWarning{rec, fmt.Sprintf("%s points at non-public address %s in a public zone", rec.Name, rec.Value)})
+		case visibility == VisibilityInternal && !nonPublic:
+			warnings = append(warnings, LintWarning{rec, fmt.Sprintf("%s points at public address %s in an internal zone", rec.Name, rec.Value)})
+		}
+	}
+	return warnings
+}
+
+func isNonPublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsPrivate() || addr.IsLoopback() || addr.IsLinkLocalUnicast() ||
+		addr.IsUnspecified() || cgnatPrefix.Contains(addr)
+}
@@ -0,0 +1,83 @@
+package coredns
+
+import (
+	"net/netip"
+	"slices"
+	"testing"
+)
+
+func TestIsNonPublicAddr(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"10.1.2.3", true},
+		{"172.16.0.1", true},
+		{"172.32.0.1", false},
+		{"192.168.1.1", true},
+		{"127.0.0.1", true},
+		{"169.254.1.1", true},
+		{"0.0.0.0", true},
+		{"100.64.0.1", true},
+		{"100.128.0.1", false},
+		{"::ffff:10.0.0.1", true},
+		{"fd00::1", true},
+		{"fe80::1", true},
+		{"::1", true},
+		{"8.8.8.8", false},
+		{"2001:4860:4860::8888", false},
+	}
+	for _, tt := range tests {
+		if got := isNonPublicAddr(netip.MustParseAddr(tt.addr)); got != tt.want {
+			t.Errorf("isNonPublicAddr(%s) = %t, want %t", tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestVisibilityFor(t *testing.T) {
+	public := []string{"example.com"}
+	internal := []string{"corp.example.com", "lan"}
+	tests := []struct {
+		domain string
+		want   ZoneVisibility
+	}{
+		{"example.com", VisibilityPublic},
+		{"www.Example.COM", VisibilityPublic},
+		// Public is checked first, so a listed public parent wins
+		{"corp.example.com", VisibilityPublic},
+		{"home.lan", VisibilityInternal},
+		{"notexample.com", VisibilityUnknown},
+		{"example.org", VisibilityUnknown},
+	}
+	for _, tt := range tests {
+		if got := VisibilityFor(tt.domain, public, internal); got != tt.want {
+			t.Errorf("VisibilityFor(%q) = %q, want %q", tt.domain, got, tt.want)
+		}
+	}
+}
+
+func TestLintAddresses(t *testing.T) {
+	records := []Record{
+		{Name: "www", Type: TypeA, Value: "203.0.113.10"},
+		{Name: "db", Type: TypeA, Value: "10.0.0.5"},
+		{Name: "v6", Type: TypeAAAA, Value: "fd00::5"},
+		{Name: "alias", Type: TypeCNAME, Value: "10.0.0.5"},
+	}
+	tests := []struct {
+		visibility ZoneVisibility
+		want       []string
+	}{
+		{VisibilityPublic, []string{"db", "v6"}},
+		{VisibilityInternal, []string{"www"}},
+		{VisibilityUnknown, nil},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, w := range LintAddresses(records, tt.visibility) {
+			got = append(got, w.Record.Name)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("LintAddresses(%q) flagged %q, want %q", tt.visibility, got, tt.want)
+		}
+	}
+}
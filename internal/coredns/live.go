@@ -0,0 +1,31 @@
+package coredns
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// QuerySOASerial asks a running DNS server for the SOA serial it is
+// currently serving for domain.
+func QuerySOASerial(server, domain string) (uint32, error) {
+	msg := new(dns.Msg)
+	msg.SetQuestion(dns.Fqdn(domain), dns.TypeSOA)
+
+	client := &dns.Client{Timeout: 3 * time.Second}
+	resp, _, err := client.Exchange(msg, server)
+	if err != nil {
+		return 0, fmt.Errorf("SOA query to %s failed: %w", server, err)
+	}
+	if resp.Rcode != dns.RcodeSuccess {
+		return 0, fmt.Errorf("SOA query to %s returned %s", server, dns.RcodeToString[resp.Rcode])
+	}
+
+	for _, rr := range resp.Answer {
+		if soa, ok := rr.(*dns.SOA); ok {
+			return soa.Serial, nil
+		}
+	}
+	return 0, fmt.Errorf("%s is not serving a SOA for %s", server, domain)
+}
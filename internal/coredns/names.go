@@ -0,0 +1,159 @@
+package coredns
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// ValidateRecordName checks a record owner name against DNS label rules.
+// "@", a leading "*" wildcard label, and absolute names ending in "." are
+// accepted. Strict mode allows underscores only at the start of a label
+// (_dmarc, _sip._tcp); lenient mode allows them anywhere.
+func ValidateRecordName(name string, strict bool) error {
+	if name == "" {
+		return fmt.Errorf("record name cannot be empty")
+	}
+	if name == "@" {
+		return nil
+	}
+
+	trimmed := strings.TrimSuffix(name, ".")
+	if trimmed == "" {
+		return fmt.Errorf("invalid record name %q", name)
+	}
+	if len(trimmed) > 253 {
+		return fmt.Errorf("record name %q is longer than 253 characters", name)
+	}
+
+	for i, label := range strings.Split(trimmed, ".") {
+		if label == "*" {
+			if i != 0 {
+				return fmt.Errorf("invalid record name %q: wildcard must be the leftmost label", name)
+			}
+			continue
+		}
+		if err := validateLabel(label, strict); err != nil {
+			return fmt.Errorf("invalid record name %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func validateLabel(label string, strict bool) error {
+	if label == "" {
+		return fmt.Errorf("empty label")
+	}
+	if len(label) > 63 {
+		return fmt.Errorf("label %q is longer than 63 characters", label)
+	}
+	if label[0] == '-' || label[len(label)-1] == '-' {
+		return fmt.Errorf("label %q cannot start or end with a hyphen", label)
+	}
+
+	for i, ch := range label {
+		switch {
+		case ch >= 'a' && ch <= 'z', ch >= 'A' && ch <= 'Z', ch >= '0' && ch <= '9', ch == '-':
+		case ch == '_':
+			if strict && i != 0 {
+				return fmt.Errorf("label %q has an underscore outside the leading position", label)
+			}
+		default:
+			return fmt.Errorf("label %q contains invalid character %q", label, ch)
+		}
+	}
+	return nil
+}
+
+// hasTargetName reports whether a record type's value is a domain name.
+func hasTargetName(rtype RecordType) bool {
+	return rtype == TypeCNAME || rtype == TypeMX || rtype == TypeNS || rtype == TypePTR
+}
+
+// NormalizeTarget makes the target of a CNAME, MX, NS, or PTR record explicit:
+// a name containing a dot is made absolute with a trailing dot, so
+// "mail.example.net" no longer silently becomes "mail.example.net.<zone>".
+// Bare labels ("mail") and "@" are left relative to the zone.
+func NormalizeTarget(rtype RecordType, value string) string {
+	if !hasTargetName(rtype) || value == "@" || strings.HasSuffix(value, ".") {
+		return value
+	}
+	if strings.Contains(value, ".") {
+		return value + "."
+	}
+	return value
+}
+
+// TargetWarning describes a likely mistake in a record target, or returns
+// "" if the target looks intentional. A bare label is relative to the zone,
+// which is rarely what's meant for MX and NS hosts outside it.
+func TargetWarning(rtype RecordType, value, domain string) string {
+	if !hasTargetName(rtype) || value == "@" || strings.Contains(value, ".") {
+		return ""
+	}
+	return fmt.Sprintf("%s target %q has no dot, so it is relative to the zone and resolves to %s.%s. Add a trailing dot if you meant an absolute name.",
+		rtype, value, value, domain)
+}
+
+// ValidateCAA checks the flag and tag of a CAA record.
+func ValidateCAA(flag uint8, tag string) error {
+	if flag != 0 && flag != 128 {
+		return fmt.Errorf("invalid CAA flag %d (allowed: 0, 128)", flag)
+	}
+	switch tag {
+	case "issue", "issuewild", "iodef":
+		return nil
+	}
+	return fmt.Errorf("invalid CAA tag %q (allowed: issue, issuewild, iodef)", tag)
+}
+
+// validateRecordValue checks that a record's value makes sense for its type:
+// A and AAAA hold an address of the right family, CNAME/MX/NS/PTR hold a
+// host name, and CAA has a known flag and tag. MX priority is range-checked
+// by its uint16 type when the form is parsed.
+func validateRecordValue(rec Record) error {
+	switch rec.Type {
+	case TypeA:
+		ip := net.ParseIP(rec.Value)
+		if ip == nil || ip.To4() == nil || strings.Contains(rec.Value, ":") {
+			return fmt.Errorf("A record value %q is not an IPv4 address", rec.Value)
+		}
+	case TypeAAAA:
+		ip := net.ParseIP(rec.Value)
+		if ip == nil || !strings.Contains(rec.Value, ":") {
+			return fmt.Errorf("AAAA record value %q is not an IPv6 address", rec.Value)
+		}
+	case TypeCNAME, TypeMX, TypeNS, TypePTR:
+		if err := validateTargetName(rec.Value); err != nil {
+			return fmt.Errorf("%s target %w", rec.Type, err)
+		}
+	case TypeCAA:
+		return ValidateCAA(rec.Flag, rec.Tag)
+	case TypeTXT:
+	default:
+		return fmt.Errorf("unsupported record type %q", rec.Type)
+	}
+	return nil
+}
+
+// validateTargetName checks a host name used as a record target. Targets
+// are host names, so unlike owner names they may not contain a wildcard.
+func validateTargetName(value string) error {
+	if value == "@" {
+		return nil
+	}
+	if strings.ContainsAny(value, " \t\"();") {
+		return fmt.Errorf("%q is not a valid host name", value)
+	}
+	if _, ok := dns.IsDomainName(value); !ok {
+		return fmt.Errorf("%q is not a valid host name", value)
+	}
+	for _, label := range strings.Split(strings.TrimSuffix(value, "."), ".") {
+		if err := validateLabel(label, false); err != nil {
+			return fmt.Errorf("%q is not a valid host name: %w", value, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package coredns
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Warning is a likely mistake in the Corefile that doesn't stop CoreDNS from
+// starting. File is empty for the main Corefile and Line is 0 when the
+// warning isn't about a particular line.
+type Warning struct {
+	File    string
+	Line    int
+	Message string
+}
+
+func (w Warning) String() string {
+	switch {
+	case w.Line == 0:
+		return w.Message
+	case w.File == "":
+		return fmt.Sprintf("line %d: %s", w.Line, w.Message)
+	default:
+		return fmt.Sprintf("%s line %d: %s", w.File, w.Line, w.Message)
+	}
+}
+
+// CheckReferences cross-checks the `file` directives of the Corefile and the
+// files it imports against the zone files in zoneDir. It warns about
+// directives naming a zone file that doesn't exist, and about zone files no
+// directive serves.
+func (m *CorefileManager) CheckReferences(zoneDir string) []Warning {
+	return m.CheckReferencesWith(zoneDir, "", "")
+}
+
+// CheckReferencesWith is CheckReferences with the main Corefile (file empty)
+// or an imported fragment replaced by content, so an edit can be checked
+// before it is saved.
+func (m *CorefileManager) CheckReferencesWith(zoneDir, file, content string) []Warning {
+	sources := map[string]string{}
+	if file == "" && content != "" {
+		sources[""] = content
+	} else if data, err := m.Read(); err == nil {
+		sources[""] = data
+	}
+	fragments, _ := m.Imports()
+	for _, name := range fragments {
+		if name == file {
+			sources[name] = content
+		} else if data, err := m.ReadFragment(name); err == nil {
+			sources[name] = data
+		}
+	}
+
+	names := make([]string, 0, len(sources))
+	for name := range sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var warnings []Warning
+	referenced := map[string]bool{}
+	for _, name := range names {
+		for _, ref := range fileDirectives(sources[name]) {
+			base := filepath.Base(ref.path)
+			referenced[base] = true
+
+			// The Corefile usually sees the zone directory under another
+			// path inside the CoreDNS container, so a file is also found by
+			// name in zoneDir.
+			path := ref.path
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(filepath.Dir(m.path), path)
+			}
+			if _, err := os.Stat(path); err == nil {
+				continue
+			}
+			if _, err := os.Stat(filepath.Join(zoneDir, base)); err == nil {
+				continue
+			}
+			warnings = append(warnings, Warning{
+				File:    name,
+				Line:    ref.line,
+				Message: fmt.Sprintf("file %s: no such zone file in %s", ref.path, filepath.Clean(zoneDir)),
+			})
+		}
+	}
+
+	entries, err := os.ReadDir(zoneDir)
+	if err != nil {
+		return warnings
+	}
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasPrefix(e.Name(), zonePrefix) || referenced[e.Name()] {
+			continue
+		}
+		warnings = append(warnings, Warning{
+			Message: fmt.Sprintf("zone file %s is not served: no `file` directive in the Corefile references it", e.Name()),
+		})
+	}
+	return warnings
+}
+
+type fileDirective struct {
+	path string
+	line int
+}
+
+// fileDirectives returns the zone file of every `file` directive in content.
+// It works on fragments as well as whole Corefiles, since it only looks at
+// the first token of each line.
+func fileDirectives(content string) []fileDirective {
+	tokens, err := tokenizeCorefile(content)
+	if err != nil {
+		return nil
+	}
+	var refs []fileDirective
+	for _, line := range corefileLines(tokens) {
+		if len(line) >= 2 && !line[0].quoted && line[0].text == "file" && !line[1].isOpen() {
+			refs = append(refs, fileDirective{path: line[1].text, line: line[0].line})
+		}
+	}
+	return refs
+}
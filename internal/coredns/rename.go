@@ -0,0 +1,229 @@
+package coredns
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// RenamePlan is the result of renaming a record owner within a zone.
+type RenamePlan struct {
+	Original string
+	Content  string // rewritten file with the SOA serial bumped
+	Records  int    // records whose owner changed
+	Targets  int    // in-zone CNAME targets updated
+	Warnings []string
+}
+
+// PreviewRename rewrites every record owned by oldName to newName and points
+// in-zone CNAMEs that targeted oldName at newName. Other references that
+// can't be rewritten safely (MX/NS/PTR targets, CNAMEs in other managed
+// zones) are reported as warnings. Nothing is written.
+func (m *ZoneManager) PreviewRename(domain, oldName, newName string) (*RenamePlan, error) {
+	if err := ValidateDomain(domain); err != nil {
+		return nil, err
+	}
+	raw, err := os.ReadFile(m.filename(domain))
+	if err != nil {
+		return nil, err
+	}
+	return m.planRename(domain, string(raw), oldName, newName)
+}
+
+// RenameOwner applies PreviewRename in a single atomic write.
+func (m *ZoneManager) RenameOwner(domain, oldName, newName string) (*RenamePlan, error) {
+	if err := ValidateDomain(domain); err != nil {
+		return nil, err
+	}
+	unlock, err := m.lock(domain)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	path := m.filename(domain)
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	plan, err := m.planRename(domain, string(raw), oldName, newName)
+	if err != nil {
+		return nil, err
+	}
+	return plan, m.writeFile(path, plan.Content)
+}
+
+func (m *ZoneManager) planRename(domain, content, oldName, newName string) (*RenamePlan, error) {
+	oldName = strings.ToLower(strings.TrimSpace(oldName))
+	newName = strings.ToLower(strings.TrimSpace(newName))
+	if oldName == "@" || newName == "@" {
+		return nil, fmt.Errorf("the zone apex can't be renamed")
+	}
+	if err := ValidateRecordName(newName, m.opts.StrictNames); err != nil {
+		return nil, err
+	}
+	if oldName == newName {
+		return nil, fmt.Errorf("old and new names are the same")
+	}
+
+	origin := dns.Fqdn(domain)
+	oldFQDN := qualifyName(oldName, origin)
+	newFQDN := qualifyName(newName, origin)
+
+	records, _, err := parseZoneFile(content, origin)
+	if err != nil {
+		return nil, fmt.Errorf("zone parse error: %w", err)
+	}
+	found := false
+	for _, r := range records {
+		switch qualifyName(r.Name, origin) {
+		case oldFQDN:
+			found = true
+		case newFQDN:
+			return nil, fmt.Errorf("%s already has records, renaming would merge them", newName)
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("no records named %s", oldName)
+	}
+
+	plan := &RenamePlan{Original: content}
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		fields := zoneFields(stripZoneComment(line))
+		if len(fields) == 0 || strings.HasPrefix(fields[0].text, "$") {
+			continue
+		}
+
+		// Owner: only lines that name one explicitly; indented lines
+		// inherit the owner of the line above and follow it
+		startsWithOwner := line[0] != ' ' && line[0] != '\t'
+		if startsWithOwner && qualifyName(fields[0].text, origin) == oldFQDN {
+			plan.Records++
+		}
+
+		// Target: the rdata field naming another host, located by position
+		// after the optional owner, TTL, and class, so neither an owner
+		// spelled like a type nor a comment is mistaken for it
+		var target *zoneField
+		t := rrTypeField(fields, startsWithOwner)
+		rtype := ""
+		if t < len(fields) {
+			rtype = strings.ToUpper(fields[t].text)
+		}
+		switch {
+		case (rtype == "CNAME" || rtype == "NS" || rtype == "PTR") && t+1 < len(fields):
+			target = &fields[t+1]
+		case rtype == "MX" && t+2 < len(fields):
+			target = &fields[t+2]
+		}
+
+		// Rewrite from the end of the line so earlier offsets stay valid
+		if target != nil && qualifyName(target.text, origin) == oldFQDN {
+			if rtype == "CNAME" {
+				line = line[:target.start] + replaceName(target.text, newName, newFQDN) + line[target.start+len(target.text):]
+				plan.Targets++
+			} else {
+				plan.Warnings = append(plan.Warnings, fmt.Sprintf("%s target on line %d still points at %s", rtype, i+1, oldName))
+			}
+		}
+		if startsWithOwner && qualifyName(fields[0].text, origin) == oldFQDN {
+			line = replaceName(fields[0].text, newName, newFQDN) + line[len(fields[0].text):]
+		}
+		lines[i] = line
+	}
+
+	plan.Warnings = append(plan.Warnings, m.externalReferences(domain, oldFQDN)...)
+	plan.Content = m.incrementSOASerial(strings.Join(lines, "\n"))
+	if err := m.Validate(domain, plan.Content); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// externalReferences lists CNAMEs in other managed zones that point at fqdn.
+func (m *ZoneManager) externalReferences(domain, fqdn string) []string {
+	var refs []string
+	domains, _ := m.List()
+	for _, d := range domains {
+		if d == domain {
+			continue
+		}
+		zf, err := m.Read(d)
+		if err != nil {
+			continue
+		}
+		for _, r := range zf.Records {
+			if r.Type == TypeCNAME && qualifyName(r.Value, dns.Fqdn(d)) == fqdn {
+				refs = append(refs, fmt.Sprintf("CNAME %s in zone %s points at %s and is not updated", r.Name, d, strings.TrimSuffix(fqdn, ".")))
+			}
+		}
+	}
+	return refs
+}
+
+// zoneField is a whitespace-separated field of a zone file line and its
+// byte offset in the line.
+type zoneField struct {
+	text  string
+	start int
+}
+
+// zoneFields splits the code part of a zone file line into fields.
+func zoneFields(code string) []zoneField {
+	var fields []zoneField
+	start := -1
+	for i := 0; i <= len(code); i++ {
+		if i == len(code) || code[i] == ' ' || code[i] == '\t' || code[i] == '\r' {
+			if start >= 0 {
+				fields = append(fields, zoneField{text: code[start:i], start: start})
+				start = -1
+			}
+		} else if start < 0 {
+			start = i
+		}
+	}
+	return fields
+}
+
+// rrTypeField returns the index of the type field of a record line: after
+// the owner, if the line has one, and up to two TTL and class fields in
+// either order.
+func rrTypeField(fields []zoneField, hasOwner bool) int {
+	i := 0
+	if hasOwner {
+		i = 1
+	}
+	for n := 0; n < 2 && i < len(fields); n++ {
+		f := strings.ToUpper(fields[i].text)
+		if _, err := ParseTTL(f); err != nil && f != "IN" && f != "CH" && f != "HS" && f != "CS" && f != "ANY" {
+			break
+		}
+		i++
+	}
+	return i
+}
+
+// qualifyName expands a zone-file name into a lowercase FQDN.
+func qualifyName(name, origin string) string {
+	name = strings.ToLower(name)
+	switch {
+	case name == "@":
+		return strings.ToLower(origin)
+	case strings.HasSuffix(name, "."):
+		return name
+	default:
+		return name + "." + strings.ToLower(origin)
+	}
+}
+
+// replaceName returns the new name written in the same style as the old
+// token: absolute if the old one was, relative otherwise.
+func replaceName(oldToken, newName, newFQDN string) string {
+	if strings.HasSuffix(oldToken, ".") {
+		return newFQDN
+	}
+	return newName
+}
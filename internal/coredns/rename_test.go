@@ -0,0 +1,89 @@
+package coredns
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestPreviewRename(t *testing.T) {
+	const domain = "example.com"
+	tests := []struct {
+		name     string
+		records  string
+		old, new string
+		want     string // lines the rewritten zone must contain
+		owners   int
+		targets  int
+		warnings int
+	}{
+		{
+			name:    "CNAME target with a trailing comment",
+			records: "app 300 IN A 192.0.2.1\nwww IN CNAME app ; alias for app\n",
+			old:     "app",
+			new:     "web",
+			want:    "web 300 IN A 192.0.2.1\nwww IN CNAME web ; alias for app\n",
+			owners:  1,
+			targets: 1,
+		},
+		{
+			name:    "owner named cname",
+			records: "cname 300 IN A 192.0.2.1\nalias 300 IN CNAME cname\n",
+			old:     "cname",
+			new:     "host",
+			want:    "host 300 IN A 192.0.2.1\nalias 300 IN CNAME host\n",
+			owners:  1,
+			targets: 1,
+		},
+		{
+			name:    "owner named like a type pointing elsewhere",
+			records: "ns 300 IN CNAME app\napp 300 IN A 192.0.2.1\n",
+			old:     "ns",
+			new:     "ns-alias",
+			want:    "ns-alias 300 IN CNAME app\n",
+			owners:  1,
+		},
+		{
+			name:    "absolute target and class before TTL",
+			records: "app IN 300 A 192.0.2.1\nwww IN 300 CNAME app.example.com.\n",
+			old:     "app",
+			new:     "web",
+			want:    "www IN 300 CNAME web.example.com.\n",
+			owners:  1,
+			targets: 1,
+		},
+		{
+			name:     "MX target is only reported",
+			records:  "mail 300 IN A 192.0.2.1\n@ 300 IN MX 10 mail\n",
+			old:      "mail",
+			new:      "mx",
+			want:     "@ 300 IN MX 10 mail\n",
+			owners:   1,
+			warnings: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestZone(t, domain, ZoneOptions{})
+			content, err := m.ReadRaw(domain)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(m.filename(domain), []byte(content+tt.records), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			plan, err := m.PreviewRename(domain, tt.old, tt.new)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(plan.Content, tt.want) {
+				t.Errorf("rewritten zone lacks %q:\n%s", tt.want, plan.Content)
+			}
+			if plan.Records != tt.owners || plan.Targets != tt.targets || len(plan.Warnings) != tt.warnings {
+				t.Errorf("Records, Targets, Warnings = %d, %d, %q; want %d, %d, %d warnings",
+					plan.Records, plan.Targets, plan.Warnings, tt.owners, tt.targets, tt.warnings)
+			}
+		})
+	}
+}
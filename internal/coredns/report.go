@@ -0,0 +1,91 @@
+package coredns
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// TypeCount is the number of records of one type in a zone.
+type TypeCount struct {
+	Type  string
+	Count int
+}
+
+// ValidationReport summarizes zone content for review before saving.
+type ValidationReport struct {
+	Total    int
+	Counts   []TypeCount // by type, most frequent first
+	External []string    // CNAMEs whose target is outside the zone
+	Dangling []string    // CNAMEs whose in-zone target has no records
+	ZeroTTL  []string    // records with a TTL of 0
+	Warnings []string
+	Error    string // why Validate rejects the content, if it does
+}
+
+// ValidateDetailed runs Validate and describes the content: record counts
+// by type, CNAME targets outside the zone or missing from it, records with
+// TTL 0, and a missing apex NS. The report covers whatever parsed before
+// any error; the error is the one Validate returns, so a nil error means
+// the content would save.
+func (m *ZoneManager) ValidateDetailed(domain, content string) (ValidationReport, error) {
+	var report ValidationReport
+	origin := dns.Fqdn(domain)
+
+	var rrs []dns.RR
+	parser := dns.NewZoneParser(strings.NewReader(content), origin, "")
+	for rr, ok := parser.Next(); ok; rr, ok = parser.Next() {
+		rrs = append(rrs, rr)
+	}
+
+	counts := make(map[string]int)
+	names := make(map[string]bool)
+	apexNS := false
+	for _, rr := range rrs {
+		hdr := rr.Header()
+		counts[dns.TypeToString[hdr.Rrtype]]++
+		names[strings.ToLower(hdr.Name)] = true
+		if hdr.Rrtype == dns.TypeNS && strings.EqualFold(hdr.Name, origin) {
+			apexNS = true
+		}
+		if hdr.Ttl == 0 {
+			report.ZeroTTL = append(report.ZeroTTL, fmt.Sprintf("%s %s", relativeName(hdr.Name, origin), dns.TypeToString[hdr.Rrtype]))
+		}
+	}
+	for _, rr := range rrs {
+		cname, ok := rr.(*dns.CNAME)
+		if !ok {
+			continue
+		}
+		entry := fmt.Sprintf("%s → %s", relativeName(cname.Hdr.Name, origin), cname.Target)
+		switch {
+		case !dns.IsSubDomain(origin, cname.Target):
+			report.External = append(report.External, entry)
+		case !names[strings.ToLower(cname.Target)]:
+			report.Dangling = append(report.Dangling, entry)
+		}
+	}
+
+	for t, n := range counts {
+		report.Counts = append(report.Counts, TypeCount{Type: t, Count: n})
+		report.Total += n
+	}
+	sort.Slice(report.Counts, func(i, j int) bool {
+		if report.Counts[i].Count != report.Counts[j].Count {
+			return report.Counts[i].Count > report.Counts[j].Count
+		}
+		return report.Counts[i].Type < report.Counts[j].Type
+	})
+
+	if len(rrs) > 0 && !apexNS {
+		report.Warnings = append(report.Warnings, "The zone apex has no NS records")
+	}
+
+	err := m.Validate(domain, content)
+	if err != nil {
+		report.Error = err.Error()
+	}
+	return report, err
+}
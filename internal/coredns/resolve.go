@@ -0,0 +1,146 @@
+package coredns
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// maxCNAMEChain bounds in-zone CNAME following, matching common resolver limits.
+const maxCNAMEChain = 8
+
+// ResolvedRecord is one answer produced by Resolve.
+type ResolvedRecord struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	TTL   uint32 `json:"ttl"`
+	Value string `json:"value"`
+}
+
+// Resolution is the answer the managed zone files would give for a query.
+type Resolution struct {
+	Name    string           `json:"name"`
+	Type    string           `json:"type"`
+	Zone    string           `json:"zone"`
+	Answers []ResolvedRecord `json:"answers"`
+	Notes   []string         `json:"notes,omitempty"`
+}
+
+// Resolve answers a query from the zone files on disk, without asking a
+// running server. In-zone CNAMEs are followed across managed zones;
+// wildcard owners are not expanded.
+func (m *ZoneManager) Resolve(name string, qtype string) (*Resolution, error) {
+	qname := dns.Fqdn(strings.ToLower(strings.TrimSpace(name)))
+	qtype = strings.ToUpper(strings.TrimSpace(qtype))
+	t, ok := dns.StringToType[qtype]
+	if !ok {
+		return nil, fmt.Errorf("unsupported record type: %s", qtype)
+	}
+
+	domains, err := m.List()
+	if err != nil {
+		return nil, err
+	}
+
+	res := &Resolution{Name: qname, Type: qtype}
+	zoneCache := map[string][]dns.RR{}
+	seen := map[string]bool{qname: true}
+
+	for hops := 0; ; hops++ {
+		zone := authoritativeZone(qname, domains)
+		if zone == "" {
+			if hops == 0 {
+				return nil, fmt.Errorf("no managed zone is authoritative for %s", qname)
+			}
+			res.Notes = append(res.Notes, fmt.Sprintf("CNAME target %s is outside the managed zones", qname))
+			return res, nil
+		}
+		if hops == 0 {
+			res.Zone = zone
+		}
+
+		rrs, ok := zoneCache[zone]
+		if !ok {
+			rrs, err = m.loadRRs(zone)
+			if err != nil {
+				return nil, err
+			}
+			zoneCache[zone] = rrs
+		}
+
+		// Answers for this name only; earlier hops' CNAMEs are already in
+		// res.Answers
+		var cname *dns.CNAME
+		found, answered := false, false
+		for _, rr := range rrs {
+			hdr := rr.Header()
+			if !strings.EqualFold(hdr.Name, qname) {
+				continue
+			}
+			found = true
+			if hdr.Rrtype == t {
+				res.Answers = append(res.Answers, toResolved(rr))
+				answered = true
+			} else if c, isCNAME := rr.(*dns.CNAME); isCNAME {
+				cname = c
+			}
+		}
+
+		if cname == nil || t == dns.TypeCNAME || answered {
+			if !found {
+				res.Notes = append(res.Notes, fmt.Sprintf("%s does not exist in zone %s (NXDOMAIN)", qname, zone))
+			} else if !answered {
+				res.Notes = append(res.Notes, fmt.Sprintf("%s exists but has no %s records (NODATA)", qname, qtype))
+			}
+			return res, nil
+		}
+
+		res.Answers = append(res.Answers, toResolved(cname))
+		qname = strings.ToLower(cname.Target)
+		if seen[qname] || hops+1 >= maxCNAMEChain {
+			res.Notes = append(res.Notes, "CNAME chain loops or is too long")
+			return res, nil
+		}
+		seen[qname] = true
+	}
+}
+
+func (m *ZoneManager) loadRRs(domain string) ([]dns.RR, error) {
+	data, err := os.ReadFile(m.filename(domain))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read zone file: %w", err)
+	}
+	parser := dns.NewZoneParser(strings.NewReader(string(data)), dns.Fqdn(domain), "")
+	var rrs []dns.RR
+	for rr, ok := parser.Next(); ok; rr, ok = parser.Next() {
+		rrs = append(rrs, rr)
+	}
+	if err := parser.Err(); err != nil {
+		return nil, fmt.Errorf("zone %s parse error: %w", domain, err)
+	}
+	return rrs, nil
+}
+
+// authoritativeZone returns the longest managed domain that qname falls under.
+func authoritativeZone(qname string, domains []string) string {
+	best := ""
+	for _, d := range domains {
+		origin := dns.Fqdn(strings.ToLower(d))
+		if (qname == origin || strings.HasSuffix(qname, "."+origin)) && len(d) > len(best) {
+			best = d
+		}
+	}
+	return best
+}
+
+func toResolved(rr dns.RR) ResolvedRecord {
+	hdr := rr.Header()
+	return ResolvedRecord{
+		Name:  hdr.Name,
+		Type:  dns.TypeToString[hdr.Rrtype],
+		TTL:   hdr.Ttl,
+		Value: strings.TrimPrefix(rr.String(), hdr.String()),
+	}
+}
@@ -0,0 +1,71 @@
+package coredns
+
+import (
+	"os"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestResolveCNAMEChain(t *testing.T) {
+	m := newTestZone(t, "example.com", ZoneOptions{})
+	if err := m.Create("example.org"); err != nil {
+		t.Fatal(err)
+	}
+	zones := map[string]string{
+		"example.com": "a 300 IN CNAME b\n" +
+			"b 300 IN CNAME c.example.com.\n" +
+			"c 300 IN CNAME www.example.org.\n" +
+			"loop1 300 IN CNAME loop2\n" +
+			"loop2 300 IN CNAME loop1\n" +
+			"out 300 IN CNAME www.example.net.\n" +
+			"txt 300 IN CNAME c\n",
+		"example.org": "www 300 IN A 192.0.2.1\n",
+	}
+	for domain, records := range zones {
+		content, err := m.ReadRaw(domain)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(m.filename(domain), []byte(content+records), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		qtype string
+		types []string // types of the answers in order
+		note  string   // substring of a note, if one is expected
+	}{
+		{"a.example.com", "A", []string{"CNAME", "CNAME", "CNAME", "A"}, ""},
+		{"b.example.com", "A", []string{"CNAME", "CNAME", "A"}, ""},
+		{"a.example.com", "CNAME", []string{"CNAME"}, ""},
+		{"txt.example.com", "TXT", []string{"CNAME", "CNAME"}, "www.example.org. exists but has no TXT records (NODATA)"},
+		{"loop1.example.com", "A", []string{"CNAME", "CNAME"}, "loops"},
+		{"out.example.com", "A", []string{"CNAME"}, "outside the managed zones"},
+		{"missing.example.com", "A", nil, "NXDOMAIN"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name+"/"+tt.qtype, func(t *testing.T) {
+			res, err := m.Resolve(tt.name, tt.qtype)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var types []string
+			for _, a := range res.Answers {
+				types = append(types, a.Type)
+			}
+			if !slices.Equal(types, tt.types) {
+				t.Errorf("answer types = %q, want %q", types, tt.types)
+			}
+			notes := strings.Join(res.Notes, "; ")
+			if tt.note == "" && notes != "" {
+				t.Errorf("unexpected notes: %s", notes)
+			}
+			if !strings.Contains(notes, tt.note) {
+				t.Errorf("notes = %q, want %q", notes, tt.note)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package coredns
+
+import (
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// ReverseZoneName returns the reverse lookup zone for a network, e.g.
+// "192.168.1.0/24" becomes "1.168.192.in-addr.arpa" and "2001:db8::/32"
+// becomes "8.b.d.0.1.0.0.2.ip6.arpa". IPv4 prefixes must fall on an octet
+// boundary and IPv6 prefixes on a nibble boundary; classless (RFC 2317)
+// delegation is not supported.
+func ReverseZoneName(cidr string) (string, error) {
+	prefix, err := netip.ParsePrefix(strings.TrimSpace(cidr))
+	if err != nil {
+		return "", fmt.Errorf("invalid CIDR %q", cidr)
+	}
+	prefix = prefix.Masked()
+	bits := prefix.Bits()
+	addr := prefix.Addr()
+
+	if addr.Is4() {
+		if bits == 0 || bits%8 != 0 {
+			return "", fmt.Errorf("IPv4 reverse zones need a /8, /16, /24, or /32 prefix, got /%d", bits)
+		}
+		octets := addr.As4()
+		labels := make([]string, 0, bits/8+2)
+		for i := bits/8 - 1; i >= 0; i-- {
+			labels = append(labels, strconv.Itoa(int(octets[i])))
+		}
+		return strings.Join(append(labels, "in-addr", "arpa"), "."), nil
+	}
+
+	if bits == 0 || bits%4 != 0 {
+		return "", fmt.Errorf("IPv6 reverse zones need a prefix length that is a multiple of 4, got /%d", bits)
+	}
+	const hexDigits = "0123456789abcdef"
+	raw := addr.As16()
+	labels := make([]string, 0, bits/4+2)
+	for i := bits/4 - 1; i >= 0; i-- {
+		b := raw[i/2]
+		nibble := b >> 4
+		if i%2 == 1 {
+			nibble = b & 0x0f
+		}
+		labels = append(labels, string(hexDigits[nibble]))
+	}
+	return strings.Join(append(labels, "ip6", "arpa"), "."), nil
+}
+
+// ReverseZoneFor finds the most specific managed reverse zone covering ip
+// and returns it along with the PTR owner name relative to that zone, e.g.
+// "192.168.1.10" in zone "1.168.192.in-addr.arpa" is "10". ok is false if
+// no managed zone covers the address.
+func (m *ZoneManager) ReverseZoneFor(ip string) (zone, name string, ok bool) {
+	arpa, err := dns.ReverseAddr(ip)
+	if err != nil {
+		return "", "", false
+	}
+	labels := dns.SplitDomainName(arpa)
+	// Stop before the bare "in-addr.arpa"/"ip6.arpa" suffix
+	for i := 1; i < len(labels)-2; i++ {
+		zone = strings.Join(labels[i:], ".")
+		if m.Exists(zone) {
+			return zone, strings.Join(labels[:i], "."), true
+		}
+	}
+	return "", "", false
+}
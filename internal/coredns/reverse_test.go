@@ -0,0 +1,65 @@
+package coredns
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReverseZoneName(t *testing.T) {
+	tests := []struct {
+		cidr    string
+		want    string
+		wantErr string
+	}{
+		{cidr: "192.168.1.0/24", want: "1.168.192.in-addr.arpa"},
+		{cidr: " 10.0.0.0/8 ", want: "10.in-addr.arpa"},
+		{cidr: "192.168.1.77/16", want: "168.192.in-addr.arpa"},
+		{cidr: "192.0.2.1/32", want: "1.2.0.192.in-addr.arpa"},
+		{cidr: "2001:db8::/32", want: "8.b.d.0.1.0.0.2.ip6.arpa"},
+		{cidr: "2001:db8:abcd::/36", want: "a.8.b.d.0.1.0.0.2.ip6.arpa"},
+		{cidr: "192.168.1.0/25", wantErr: "need a /8, /16, /24, or /32 prefix"},
+		{cidr: "0.0.0.0/0", wantErr: "need a /8, /16, /24, or /32 prefix"},
+		{cidr: "2001:db8::/30", wantErr: "multiple of 4"},
+		{cidr: "192.168.1.0", wantErr: "invalid CIDR"},
+	}
+	for _, tt := range tests {
+		got, err := ReverseZoneName(tt.cidr)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ReverseZoneName(%q) error = %v, want %q", tt.cidr, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ReverseZoneName(%q) = %q, %v, want %q", tt.cidr, got, err, tt.want)
+		}
+	}
+}
+
+func TestReverseZoneFor(t *testing.T) {
+	m := newTestZone(t, "168.192.in-addr.arpa", ZoneOptions{})
+	for _, zone := range []string{"1.168.192.in-addr.arpa", "8.b.d.0.1.0.0.2.ip6.arpa"} {
+		if err := m.Create(zone); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		ip   string
+		zone string
+		name string
+	}{
+		// The most specific zone wins
+		{"192.168.1.10", "1.168.192.in-addr.arpa", "10"},
+		{"192.168.2.10", "168.192.in-addr.arpa", "10.2"},
+		{"2001:db8::1", "8.b.d.0.1.0.0.2.ip6.arpa", "1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0"},
+		{"10.0.0.1", "", ""},
+		{"not-an-ip", "", ""},
+	}
+	for _, tt := range tests {
+		zone, name, ok := m.ReverseZoneFor(tt.ip)
+		if ok != (tt.zone != "") || zone != tt.zone || name != tt.name {
+			t.Errorf("ReverseZoneFor(%q) = %q, %q, %t, want %q, %q", tt.ip, zone, name, ok, tt.zone, tt.name)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package coredns
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// maxSearchPattern caps the length of glob and regex search queries. Go's
+// regexp is RE2-based so matching is linear, but a huge pattern still costs
+// memory to compile.
+const maxSearchPattern = 256
+
+// SearchMode selects how a search query is interpreted.
+type SearchMode string
+
+const (
+	SearchSubstring SearchMode = "substring"
+	SearchGlob      SearchMode = "glob"
+	SearchRegex     SearchMode = "regex"
+)
+
+// SearchResult is a record matched by a cross-zone search.
+type SearchResult struct {
+	Domain string
+	FQDN   string
+	Record Record
+}
+
+// Matcher reports whether a record name or value matches a search query.
+type Matcher func(s string) bool
+
+// NewMatcher compiles query for the given mode. Matching is
+// case-insensitive; globs must match the whole string ("*.prod.*"), while
+// substrings and regular expressions may match anywhere.
+func NewMatcher(query string, mode SearchMode) (Matcher, error) {
+	if query == "" {
+		return nil, fmt.Errorf("search query cannot be empty")
+	}
+
+	switch mode {
+	case SearchSubstring, "":
+		q := strings.ToLower(query)
+		return func(s string) bool { return strings.Contains(strings.ToLower(s), q) }, nil
+	case SearchGlob, SearchRegex:
+	default:
+		return nil, fmt.Errorf("unknown search mode %q", mode)
+	}
+
+	if len(query) > maxSearchPattern {
+		return nil, fmt.Errorf("search pattern is longer than %d characters", maxSearchPattern)
+	}
+
+	expr := query
+	if mode == SearchGlob {
+		expr = globToRegexp(query)
+	}
+	if _, err := regexp.Compile(expr); err != nil {
+		return nil, fmt.Errorf("invalid pattern: %s", strings.TrimPrefix(err.Error(), "error parsing regexp: "))
+	}
+	re := regexp.MustCompile("(?i)" + expr)
+	return re.MatchString, nil
+}
+
+// globToRegexp translates * and ? wildcards into an anchored expression,
+// quoting everything else.
+func globToRegexp(glob string) string {
+	var b strings.Builder
+	b.WriteString("^")
+	for _, r := range glob {
+		switch r {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return b.String()
+}
+
+// Search returns the records across all zones whose fully-qualified name or
+// value matches. Zones that fail to parse are reported in errs and skipped.
+func (m *ZoneManager) Search(match Matcher) (results []SearchResult, errs []string, err error) {
+	domains, err := m.List()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, domain := range domains {
+		zf, err := m.Read(domain)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", domain, err))
+			continue
+		}
+		for _, rec := range zf.Records {
+			fqdn := recordFQDN(rec.Name, domain)
+			if match(fqdn) || match(rec.Value) {
+				results = append(results, SearchResult{Domain: domain, FQDN: fqdn, Record: rec})
+			}
+		}
+	}
+	return results, errs, nil
+}
+
+// recordFQDN expands a zone-relative record name into a name without the
+// trailing dot, e.g. "www" in example.com becomes "www.example.com".
+func recordFQDN(name, domain string) string {
+	switch {
+	case name == "@":
+		return domain
+	case strings.HasSuffix(name, "."):
+		return strings.TrimSuffix(name, ".")
+	default:
+		return name + "." + domain
+	}
+}
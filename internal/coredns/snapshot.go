@@ -0,0 +1,82 @@
+package coredns
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"sort"
+	"time"
+)
+
+// Snapshot records the content of managed files at a point in time, so
+// later state can be compared against what CoreDNS last loaded.
+type Snapshot struct {
+	Taken time.Time
+	files map[string]snapshotFile
+}
+
+type snapshotFile struct {
+	path    string
+	hash    string
+	content string
+}
+
+// FileChange describes a managed file that differs from a snapshot.
+type FileChange struct {
+	Name    string
+	Status  string // modified, added, removed
+	ModTime time.Time
+}
+
+// TakeSnapshot reads the given files (display name -> path). Unreadable
+// files are left out and therefore show up as added once they appear.
+func TakeSnapshot(paths map[string]string) *Snapshot {
+	s := &Snapshot{Taken: time.Now(), files: make(map[string]snapshotFile)}
+	for name, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		sum := sha256.Sum256(data)
+		s.files[name] = snapshotFile{
+			path:    path,
+			hash:    hex.EncodeToString(sum[:]),
+			content: string(data),
+		}
+	}
+	return s
+}
+
+// Changes lists files whose content differs between s and current.
+func (s *Snapshot) Changes(current *Snapshot) []FileChange {
+	var changes []FileChange
+	for name, cur := range current.files {
+		prev, ok := s.files[name]
+		switch {
+		case !ok:
+			changes = append(changes, FileChange{Name: name, Status: "added", ModTime: modTime(cur.path)})
+		case prev.hash != cur.hash:
+			changes = append(changes, FileChange{Name: name, Status: "modified", ModTime: modTime(cur.path)})
+		}
+	}
+	for name := range s.files {
+		if _, ok := current.files[name]; !ok {
+			changes = append(changes, FileChange{Name: name, Status: "removed"})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Name < changes[j].Name })
+	return changes
+}
+
+// Content returns the snapshotted content of a file, or "" if absent.
+func (s *Snapshot) Content(name string) string {
+	return s.files[name].content
+}
+
+func modTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
@@ -0,0 +1,61 @@
+package coredns
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// ttlUnits maps BIND TTL suffixes to seconds.
+var ttlUnits = map[byte]uint64{
+	's': 1,
+	'm': 60,
+	'h': 60 * 60,
+	'd': 24 * 60 * 60,
+	'w': 7 * 24 * 60 * 60,
+}
+
+// ParseTTL parses a TTL in seconds ("3600") or with BIND-style unit
+// suffixes, alone or combined ("1h", "1d", "1h30m", "1W2D"). Units are
+// case-insensitive.
+func ParseTTL(s string) (uint32, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" {
+		return 0, fmt.Errorf("TTL cannot be empty")
+	}
+	if strings.HasPrefix(s, "-") {
+		return 0, fmt.Errorf("TTL %q cannot be negative", s)
+	}
+
+	var total, num uint64
+	digits := false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= '0' && c <= '9':
+			num = num*10 + uint64(c-'0')
+			digits = true
+			if num > math.MaxUint32 {
+				return 0, fmt.Errorf("TTL %q is too large (maximum %d seconds)", s, uint32(math.MaxUint32))
+			}
+		case ttlUnits[c] != 0:
+			if !digits {
+				return 0, fmt.Errorf("invalid TTL %q: unit %q needs a number before it", s, c)
+			}
+			total += num * ttlUnits[c]
+			num, digits = 0, false
+		default:
+			return 0, fmt.Errorf("invalid TTL %q: use seconds or s, m, h, d, w suffixes (e.g. 3600, 1h, 1h30m)", s)
+		}
+		if total > math.MaxUint32 {
+			return 0, fmt.Errorf("TTL %q is too large (maximum %d seconds)", s, uint32(math.MaxUint32))
+		}
+	}
+
+	// A trailing bare number counts as seconds ("1h30" is 1h + 30s, as in BIND)
+	total += num
+	if total > math.MaxUint32 {
+		return 0, fmt.Errorf("TTL %q is too large (maximum %d seconds)", s, uint32(math.MaxUint32))
+	}
+	return uint32(total), nil
+}
@@ -0,0 +1,47 @@
+package coredns
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseTTL(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    uint32
+		wantErr string
+	}{
+		{in: "3600", want: 3600},
+		{in: " 300 ", want: 300},
+		{in: "0", want: 0},
+		{in: "30s", want: 30},
+		{in: "5m", want: 300},
+		{in: "1h", want: 3600},
+		{in: "1d", want: 86400},
+		{in: "1w", want: 604800},
+		{in: "1h30m", want: 5400},
+		{in: "1W2D", want: 777600},
+		{in: "1h30", want: 3630},
+		{in: "4294967295", want: 4294967295},
+		{in: "", wantErr: "cannot be empty"},
+		{in: "-5", wantErr: "cannot be negative"},
+		{in: "h", wantErr: "needs a number before it"},
+		{in: "1hh", wantErr: "needs a number before it"},
+		{in: "1y", wantErr: "invalid TTL"},
+		{in: "1.5h", wantErr: "invalid TTL"},
+		{in: "4294967296", wantErr: "too large"},
+		{in: "7102w", wantErr: "too large"},
+	}
+	for _, tt := range tests {
+		got, err := ParseTTL(tt.in)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParseTTL(%q) error = %v, want %q", tt.in, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ParseTTL(%q) = %d, %v, want %d", tt.in, got, err, tt.want)
+		}
+	}
+}
@@ -1,13 +1,22 @@
 package coredns
 
 import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
+	"log"
 	"os"
 	"path/filepath"
 	"regexp"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/miekg/dns"
@@ -17,6 +26,18 @@ const zonePrefix = "db."
 
 var validDomainRe = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9.-]*[a-zA-Z0-9]$`)
 
+// ErrRecordExists is returned by AddRecord when an identical record is
+// already in the zone.
+var ErrRecordExists = errors.New("record already exists")
+
+// ErrRecordNotFound is returned by RemoveRecord and UpdateRecord when no
+// record matches.
+var ErrRecordNotFound = errors.New("record not found")
+
+// ErrVersionConflict is returned by Write when the file on disk no longer
+// matches the version the caller loaded.
+var ErrVersionConflict = errors.New("file changed since you loaded it")
+
 type RecordType string
 
 const (
@@ -26,14 +47,49 @@ const (
 	TypeMX    RecordType = "MX"
 	TypeTXT   RecordType = "TXT"
 	TypeNS    RecordType = "NS"
+	TypeCAA   RecordType = "CAA"
+	TypePTR   RecordType = "PTR"
 )
 
 type Record struct {
 	Name     string     // relative to zone (e.g., "app", "@")
-	Type     RecordType // A, AAAA, CNAME, MX, TXT, NS
+	Type     RecordType // A, AAAA, CNAME, MX, TXT, NS, CAA, PTR
 	TTL      uint32
 	Value    string
 	Priority uint16 // MX only
+	Flag     uint8  // CAA only: 0, or 128 for critical
+	Tag      string // CAA only: issue, issuewild, or iodef
+}
+
+// sortTypeOrder is the type order SortRecords uses; other types follow
+// alphabetically.
+var sortTypeOrder = []RecordType{TypeA, TypeAAAA, TypeCNAME, TypeMX, TypeTXT, "SRV", TypeNS}
+
+// SortRecords orders records by type, then by name with the apex first. The
+// sort is stable, so records with the same name and type keep their file
+// order.
+func SortRecords(records []Record) {
+	rank := func(t RecordType) int {
+		for i, known := range sortTypeOrder {
+			if t == known {
+				return i
+			}
+		}
+		return len(sortTypeOrder)
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		a, b := records[i], records[j]
+		if ra, rb := rank(a.Type), rank(b.Type); ra != rb {
+			return ra < rb
+		}
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+		if (a.Name == "@") != (b.Name == "@") {
+			return a.Name == "@"
+		}
+		return strings.ToLower(a.Name) < strings.ToLower(b.Name)
+	})
 }
 
 type SOAData struct {
@@ -51,14 +107,54 @@ type ZoneFile struct {
 	Records []Record
 	SOA     *SOAData
 	Raw     string
+	Version string // ContentVersion of Raw, to submit back to Write
+}
+
+// managedHeader marks files written by this tool.
+const managedHeader = "; Managed by simple-coredns-manager"
+
+// ZoneOptions tunes how zone files are written.
+type ZoneOptions struct {
+	// ManagedHeader prepends a "; Managed by simple-coredns-manager" comment
+	// with the last-modified time to every file written.
+	ManagedHeader bool
+
+	// StrictNames restricts underscores in record names to the start of a
+	// label; when false they are allowed anywhere.
+	StrictNames bool
+
+	// NormalizeTargets stores dotted CNAME, MX, and NS targets as absolute
+	// names with a trailing dot instead of as typed.
+	NormalizeTargets bool
+
+	// EpochSerials sets SOA serials to the Unix time instead of the
+	// YYYYMMDDNN date format.
+	EpochSerials bool
+
+	// Backups receives the previous content of a zone file before every
+	// overwrite or delete; nil keeps no backups.
+	Backups *Backups
 }
 
 type ZoneManager struct {
-	dir string
+	dir  string
+	opts ZoneOptions
+
+	locks sync.Map // domain -> *sync.Mutex
+	cache sync.Map // domain -> parsedZone
+	prev  sync.Map // domain -> undoState of the last write, for Undo
+}
+
+// parsedZone is a parse-cache entry, valid while the file's mtime and size
+// are unchanged.
+type parsedZone struct {
+	modTime time.Time
+	size    int64
+	zone    *ZoneFile
 }
 
-func NewZoneManager(dir string) *ZoneManager {
-	return &ZoneManager{dir: dir}
+func NewZoneManager(dir string, opts ZoneOptions) *ZoneManager {
+	return &ZoneManager{dir: dir, opts: opts}
 }
 
 // ValidateDomain validates the domain part (without db. prefix).
@@ -78,10 +174,73 @@ func ValidateDomain(domain string) error {
 	return nil
 }
 
+// lockTimeout bounds how long a write waits for another process holding a
+// zone file's lock.
+const lockTimeout = 10 * time.Second
+
+// errLockTimeout is returned when a zone's lock file stays locked by another
+// process for longer than lockTimeout.
+var errLockTimeout = errors.New("zone file is locked by another process")
+
+// lock serializes read-modify-write cycles on a single zone file, so two
+// concurrent edits can't both start from the pre-change content. Besides the
+// in-process mutex it takes an advisory flock on a ".db.<domain>.lock" file
+// next to the zone, so other manager instances and scripts using the same
+// lock file are serialized too. The lock file is removed again when the
+// zone doesn't exist by the time it is unlocked, as after a delete.
+func (m *ZoneManager) lock(domain string) (func(), error) {
+	v, _ := m.locks.LoadOrStore(domain, &sync.Mutex{})
+	mu := v.(*sync.Mutex)
+	mu.Lock()
+
+	lockPath := filepath.Join(m.dir, "."+zonePrefix+domain+".lock")
+	unlockFile, err := flockFile(lockPath, lockTimeout)
+	if err != nil {
+		mu.Unlock()
+		if errors.Is(err, errLockTimeout) {
+			return nil, fmt.Errorf("%w (waited %s)", err, lockTimeout)
+		}
+		return nil, fmt.Errorf("failed to lock zone file: %w", err)
+	}
+	return func() {
+		if _, err := os.Stat(m.filename(domain)); os.IsNotExist(err) {
+			os.Remove(lockPath)
+		}
+		unlockFile()
+		mu.Unlock()
+	}, nil
+}
+
 func (m *ZoneManager) filename(domain string) string {
 	return filepath.Join(m.dir, zonePrefix+domain)
 }
 
+// Path returns the zone file location for a domain.
+func (m *ZoneManager) Path(domain string) string {
+	return m.filename(domain)
+}
+
+// resolveFileDirective maps the path argument of a Corefile `file`
+// directive to a managed domain. Relative paths are resolved against
+// baseDir, the Corefile's directory.
+func (m *ZoneManager) resolveFileDirective(path, baseDir string) (domain string, managed, missing bool) {
+	name := filepath.Base(path)
+	domain = strings.TrimPrefix(name, zonePrefix)
+	if domain == name || ValidateDomain(domain) != nil {
+		return "", false, false
+	}
+
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(baseDir, path)
+	}
+	// Only files in our own zone directory are editable here; the path may
+	// also be the container-side mount point, so compare by name as well.
+	_, statErr := os.Stat(path)
+	managed = filepath.Clean(filepath.Dir(path)) == filepath.Clean(m.dir) || m.Exists(domain)
+	missing = os.IsNotExist(statErr) && !m.Exists(domain)
+	return domain, managed, missing
+}
+
 // List returns domain names (without db. prefix) of all zone files.
 func (m *ZoneManager) List() ([]string, error) {
 	entries, err := os.ReadDir(m.dir)
@@ -109,21 +268,54 @@ func (m *ZoneManager) Read(domain string) (*ZoneFile, error) {
 		return nil, err
 	}
 
-	data, err := os.ReadFile(m.filename(domain))
+	path := m.filename(domain)
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read zone file: %w", err)
+	}
+	if cached, ok := m.cache.Load(domain); ok {
+		entry := cached.(parsedZone)
+		if entry.modTime.Equal(info.ModTime()) && entry.size == info.Size() {
+			zf := *entry.zone
+			zf.Records = append([]Record(nil), entry.zone.Records...)
+			return &zf, nil
+		}
+	}
+
+	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read zone file: %w", err)
 	}
 
 	raw := string(data)
 	origin := dns.Fqdn(domain)
-	records, soa := parseZoneFile(raw, origin)
+	records, soa, _ := parseZoneFile(raw, origin)
 
-	return &ZoneFile{
+	zf := &ZoneFile{
 		Domain:  domain,
 		Records: records,
 		SOA:     soa,
 		Raw:     raw,
-	}, nil
+		Version: ContentVersion(raw),
+	}
+	m.cache.Store(domain, parsedZone{modTime: info.ModTime(), size: info.Size(), zone: zf})
+
+	out := *zf
+	out.Records = append([]Record(nil), records...)
+	return &out, nil
+}
+
+// Stat returns the modification time and size of a zone file, so callers can
+// cheaply tell whether it changed since they read it.
+func (m *ZoneManager) Stat(domain string) (modTime time.Time, size int64, err error) {
+	if err := ValidateDomain(domain); err != nil {
+		return time.Time{}, 0, err
+	}
+	info, err := os.Stat(m.filename(domain))
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	return info.ModTime(), info.Size(), nil
 }
 
 // ReadRaw returns the raw content of a zone file.
@@ -138,20 +330,73 @@ func (m *ZoneManager) ReadRaw(domain string) (string, error) {
 	return string(data), nil
 }
 
-// Write saves zone file content, auto-incrementing the SOA serial.
-func (m *ZoneManager) Write(domain, content string) error {
+// ExportNormalized writes the zone in canonical form, one record per line
+// with absolute lowercase owner names and explicit TTL and class. Records are
+// streamed from the parser to w, so large zones are never held in memory.
+// Writes replace the file by rename, so the open file stays consistent
+// without holding the zone lock for the duration of a slow download.
+func (m *ZoneManager) ExportNormalized(domain string, w io.Writer) error {
+	if err := ValidateDomain(domain); err != nil {
+		return err
+	}
+	f, err := os.Open(m.filename(domain))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	bw := bufio.NewWriter(w)
+	parser := dns.NewZoneParser(bufio.NewReader(f), dns.Fqdn(domain), "")
+	for rr, ok := parser.Next(); ok; rr, ok = parser.Next() {
+		rr.Header().Name = dns.CanonicalName(rr.Header().Name)
+		if _, err := bw.WriteString(rr.String() + "\n"); err != nil {
+			return err
+		}
+	}
+	if err := parser.Err(); err != nil {
+		return fmt.Errorf("zone parse error: %w", err)
+	}
+	return bw.Flush()
+}
+
+// ContentVersion returns an opaque token identifying content, used to detect
+// a file being changed between loading it into an editor and saving it.
+func ContentVersion(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Write saves zone file content, auto-incrementing the SOA serial. If version
+// is not empty, the save is refused with ErrVersionConflict unless the file
+// on disk still has that version.
+func (m *ZoneManager) Write(domain, content, version string) error {
 	if err := ValidateDomain(domain); err != nil {
 		return err
 	}
+	unlock, err := m.lock(domain)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if version != "" {
+		current, err := os.ReadFile(m.filename(domain))
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to read zone file: %w", err)
+		}
+		if err != nil || ContentVersion(string(current)) != version {
+			return ErrVersionConflict
+		}
+	}
 
 	content = strings.ReplaceAll(content, "\r\n", "\n")
 	if !strings.HasSuffix(content, "\n") {
 		content += "\n"
 	}
 
-	content = incrementSOASerial(content)
+	content = m.incrementSOASerial(content)
 
-	return atomicWrite(m.filename(domain), content)
+	return m.writeFile(m.filename(domain), content)
 }
 
 // Create generates a new zone file with default SOA and NS records.
@@ -159,15 +404,34 @@ func (m *ZoneManager) Create(domain string) error {
 	if err := ValidateDomain(domain); err != nil {
 		return err
 	}
+	unlock, err := m.lock(domain)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	content, err := m.PreviewCreate(domain)
+	if err != nil {
+		return err
+	}
+	return m.writeFile(m.filename(domain), content)
+}
+
+// PreviewCreate returns the default zone file content Create would write,
+// without touching the disk.
+func (m *ZoneManager) PreviewCreate(domain string) (string, error) {
+	if err := ValidateDomain(domain); err != nil {
+		return "", err
+	}
 
 	if m.Exists(domain) {
-		return fmt.Errorf("zone file already exists: %s", domain)
+		return "", fmt.Errorf("zone file already exists: %s", domain)
 	}
 
-	serial := time.Now().Format("20060102") + "01"
+	serial := m.initialSerial()
 	origin := dns.Fqdn(domain)
 
-	content := fmt.Sprintf(`$ORIGIN %s
+	return fmt.Sprintf(`$ORIGIN %s
 $TTL 3600
 
 @ IN SOA ns1.%s admin.%s (
@@ -179,9 +443,173 @@ $TTL 3600
 )
 
 @ IN NS ns1.%s
-`, origin, origin, origin, serial, origin)
+`, origin, origin, origin, serial, origin), nil
+}
 
-	return atomicWrite(m.filename(domain), content)
+// BackupsEnabled reports whether zone files are backed up before changes.
+func (m *ZoneManager) BackupsEnabled() bool {
+	return m.opts.Backups.Enabled()
+}
+
+// ListBackups returns the saved previous versions of a zone, newest first.
+func (m *ZoneManager) ListBackups(domain string) ([]Backup, error) {
+	if err := ValidateDomain(domain); err != nil {
+		return nil, err
+	}
+	return m.opts.Backups.List(zonePrefix + domain)
+}
+
+// ReadBackup returns the content of one of a zone's backups.
+func (m *ZoneManager) ReadBackup(domain, id string) (string, error) {
+	if err := ValidateDomain(domain); err != nil {
+		return "", err
+	}
+	return m.opts.Backups.Read(zonePrefix+domain, id)
+}
+
+// RestoreBackup replaces a zone with one of its backups, as Restore does.
+func (m *ZoneManager) RestoreBackup(domain, id string) error {
+	content, err := m.ReadBackup(domain, id)
+	if err != nil {
+		return err
+	}
+	if err := m.Validate(domain, content); err != nil {
+		return fmt.Errorf("backup does not validate: %w", err)
+	}
+	return m.Restore(domain, content)
+}
+
+// Restore replaces a zone with earlier content, creating the file if needed.
+// The serial counts on from the current file's rather than the earlier one's,
+// so secondaries still see an increase.
+func (m *ZoneManager) Restore(domain, content string) error {
+	if err := ValidateDomain(domain); err != nil {
+		return err
+	}
+	unlock, err := m.lock(domain)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	if !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+
+	return m.writeAhead(m.filename(domain), content)
+}
+
+// ErrNothingToUndo is returned by Undo when no earlier content of the zone
+// is known.
+var ErrNothingToUndo = errors.New("no earlier version to undo to")
+
+// ErrUndoConflict is returned by Undo when the zone file was changed by
+// something else after the write being undone.
+var ErrUndoConflict = errors.New("the zone file changed since the last change made here; undoing would overwrite that")
+
+// undoState is what Undo needs about a zone's last write: the content
+// before it, and the version the write left on disk.
+type undoState struct {
+	content string
+	version string
+}
+
+// CanUndo reports whether Undo has an earlier version of the zone.
+func (m *ZoneManager) CanUndo(domain string) bool {
+	_, ok := m.prev.Load(domain)
+	return ok
+}
+
+// Undo puts back the content the zone had before its last write by this
+// manager, as long as the file is still as that write left it. Only one
+// level is kept: undoing twice redoes the change. Earlier versions are only
+// remembered until the manager restarts; a deleted zone is brought back
+// from its backups instead.
+func (m *ZoneManager) Undo(domain string) error {
+	if err := ValidateDomain(domain); err != nil {
+		return err
+	}
+	unlock, err := m.lock(domain)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	v, ok := m.prev.Load(domain)
+	if !ok {
+		return ErrNothingToUndo
+	}
+	prev := v.(undoState)
+	current, err := os.ReadFile(m.filename(domain))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read zone file: %w", err)
+	}
+	if err != nil || ContentVersion(string(current)) != prev.version {
+		return ErrUndoConflict
+	}
+	return m.writeAhead(m.filename(domain), prev.content)
+}
+
+// writeAhead writes earlier content of a zone back, with the serial counting
+// on from the current file's rather than the earlier one's, so secondaries
+// still see an increase. Callers must hold the zone lock.
+func (m *ZoneManager) writeAhead(path, content string) error {
+	if raw, err := os.ReadFile(path); err == nil {
+		var current string
+		replaceSOASerial(string(raw), func(old string) string {
+			current = old
+			return old
+		})
+		content = replaceSOASerial(content, func(old string) string {
+			if serialLess(old, current) {
+				return current
+			}
+			return old
+		})
+	}
+	return m.writeFile(path, m.incrementSOASerial(content))
+}
+
+// serialLess reports whether serial a is lower than serial b, comparing them
+// as the 32-bit numbers they are. A serial that doesn't parse is never lower.
+func serialLess(a, b string) bool {
+	x, err := strconv.ParseUint(a, 10, 32)
+	if err != nil {
+		return false
+	}
+	y, err := strconv.ParseUint(b, 10, 32)
+	return err == nil && x < y
+}
+
+// Backup saves the zone's current content as a backup and returns its ID.
+// Unlike the backup every write takes, it is kept even when backups are
+// disabled with BACKUP_KEEP=0, for changes that replace the whole zone.
+func (m *ZoneManager) Backup(domain string) (string, error) {
+	if err := ValidateDomain(domain); err != nil {
+		return "", err
+	}
+	raw, err := m.ReadRaw(domain)
+	if err != nil {
+		return "", err
+	}
+	return m.opts.Backups.Take(zonePrefix+domain, raw)
+}
+
+// backup saves the current content of the zone file at path, if any, and
+// returns it so the caller can remember it for Undo once its change is made.
+func (m *ZoneManager) backup(path string) (prev string, ok bool, err error) {
+	raw, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("failed to read zone file for backup: %w", err)
+	}
+	if err := m.opts.Backups.Save(filepath.Base(path), string(raw)); err != nil {
+		return "", false, err
+	}
+	return string(raw), true, nil
 }
 
 // Delete removes a zone file.
@@ -189,11 +617,25 @@ func (m *ZoneManager) Delete(domain string) error {
 	if err := ValidateDomain(domain); err != nil {
 		return err
 	}
+	unlock, err := m.lock(domain)
+	if err != nil {
+		return err
+	}
+	defer unlock()
 	path := m.filename(domain)
 	if _, err := os.Stat(path); os.IsNotExist(err) {
 		return fmt.Errorf("zone file does not exist: %s", domain)
 	}
-	return os.Remove(path)
+	if _, _, err := m.backup(path); err != nil {
+		return err
+	}
+	m.cache.Delete(domain)
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+	// A zone created again under the same name starts without undo
+	m.prev.Delete(domain)
+	return nil
 }
 
 // Exists checks if a zone file exists.
@@ -210,6 +652,20 @@ func (m *ZoneManager) AddRecord(domain string, rec Record) error {
 	if err := ValidateDomain(domain); err != nil {
 		return err
 	}
+	if err := ValidateRecordName(rec.Name, m.opts.StrictNames); err != nil {
+		return err
+	}
+	if err := validateRecordValue(rec); err != nil {
+		return err
+	}
+	if m.opts.NormalizeTargets {
+		rec.Value = NormalizeTarget(rec.Type, rec.Value)
+	}
+	unlock, err := m.lock(domain)
+	if err != nil {
+		return err
+	}
+	defer unlock()
 
 	path := m.filename(domain)
 	raw, err := os.ReadFile(path)
@@ -218,15 +674,21 @@ func (m *ZoneManager) AddRecord(domain string, rec Record) error {
 	}
 
 	content := string(raw)
+	if hasRecord(content, dns.Fqdn(domain), rec) {
+		return ErrRecordExists
+	}
 	if !strings.HasSuffix(content, "\n") {
 		content += "\n"
 	}
 
 	line := formatRecord(rec)
 	content += line + "\n"
-	content = incrementSOASerial(content)
+	content = m.incrementSOASerial(content)
+	if err := m.Validate(domain, content); err != nil {
+		return err
+	}
 
-	return atomicWrite(path, content)
+	return m.writeFile(path, content)
 }
 
 // RemoveRecord removes the first matching record line from the zone file.
@@ -234,6 +696,11 @@ func (m *ZoneManager) RemoveRecord(domain string, name string, rtype RecordType,
 	if err := ValidateDomain(domain); err != nil {
 		return err
 	}
+	unlock, err := m.lock(domain)
+	if err != nil {
+		return err
+	}
+	defer unlock()
 
 	path := m.filename(domain)
 	raw, err := os.ReadFile(path)
@@ -255,12 +722,209 @@ func (m *ZoneManager) RemoveRecord(domain string, name string, rtype RecordType,
 	}
 
 	if !removed {
-		return fmt.Errorf("record not found")
+		return ErrRecordNotFound
 	}
 
 	content := strings.Join(result, "\n")
-	content = incrementSOASerial(content)
-	return atomicWrite(path, content)
+	content = m.incrementSOASerial(content)
+	return m.writeFile(path, content)
+}
+
+// UpdateRecord replaces the first record matching old with rec, keeping its
+// position in the file. old.Value identifies the record the same way as in
+// RemoveRecord. The file is left untouched if no record matches.
+func (m *ZoneManager) UpdateRecord(domain string, old, rec Record) error {
+	if err := ValidateDomain(domain); err != nil {
+		return err
+	}
+	if err := ValidateRecordName(rec.Name, m.opts.StrictNames); err != nil {
+		return err
+	}
+	if err := validateRecordValue(rec); err != nil {
+		return err
+	}
+	if m.opts.NormalizeTargets {
+		rec.Value = NormalizeTarget(rec.Type, rec.Value)
+	}
+	unlock, err := m.lock(domain)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	path := m.filename(domain)
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	origin := dns.Fqdn(domain)
+	lines := strings.Split(string(raw), "\n")
+	updated := false
+	for i, line := range lines {
+		if matchesRecord(line, old.Name, old.Type, old.Value, origin) {
+			lines[i] = formatRecord(rec)
+			updated = true
+			break
+		}
+	}
+	if !updated {
+		return ErrRecordNotFound
+	}
+
+	content := m.incrementSOASerial(strings.Join(lines, "\n"))
+	if err := m.Validate(domain, content); err != nil {
+		return err
+	}
+	return m.writeFile(path, content)
+}
+
+// WriteSOA replaces the zone's SOA record with soa, keeping the owner, TTL,
+// and class written before the SOA keyword and the rest of the file as is.
+// The record is rewritten in the multi-line form with the existing serial,
+// which is then incremented as on any other save. soa.Serial is ignored.
+func (m *ZoneManager) WriteSOA(domain string, soa SOAData) error {
+	if err := ValidateDomain(domain); err != nil {
+		return err
+	}
+	for _, host := range []struct{ field, name string }{{"primary NS", soa.MName}, {"admin mailbox", soa.RName}} {
+		if _, ok := dns.IsDomainName(host.name); !ok || host.name == "" || strings.ContainsAny(host.name, " \t()\";") {
+			return fmt.Errorf("invalid SOA %s %q", host.field, host.name)
+		}
+	}
+	unlock, err := m.lock(domain)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	path := m.filename(domain)
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	content := string(raw)
+
+	_, current, err := parseZoneFile(content, dns.Fqdn(domain))
+	if err != nil {
+		return fmt.Errorf("zone parse error: %w", err)
+	}
+	lines := strings.Split(content, "\n")
+	start, end, prefix := findSOABlock(lines)
+	if current == nil || start < 0 {
+		return fmt.Errorf("zone file has no SOA record")
+	}
+
+	block := fmt.Sprintf(`%s %s %s (
+    %d ; serial
+    %-10d ; refresh
+    %-10d ; retry
+    %-10d ; expire
+    %-10d ; minimum TTL
+)`, prefix, soa.MName, soa.RName, current.Serial, soa.Refresh, soa.Retry, soa.Expire, soa.MinTTL)
+
+	lines = append(lines[:start], append([]string{block}, lines[end+1:]...)...)
+	content = m.incrementSOASerial(strings.Join(lines, "\n"))
+	if err := m.Validate(domain, content); err != nil {
+		return err
+	}
+	return m.writeFile(path, content)
+}
+
+// findSOABlock locates the SOA record in a zone file's lines, following
+// parentheses across lines. prefix is the start line up to and including
+// the SOA keyword (owner, TTL, class). start is -1 if there is no SOA.
+func findSOABlock(lines []string) (start, end int, prefix string) {
+	for i, line := range lines {
+		code := stripZoneComment(line)
+		fields := strings.Fields(code)
+		idx := -1
+		for j, f := range fields {
+			if j > 3 || strings.HasPrefix(f, `"`) {
+				break
+			}
+			if strings.EqualFold(f, "SOA") {
+				idx = j
+				break
+			}
+		}
+		if idx < 0 || strings.HasPrefix(strings.TrimSpace(code), "$") {
+			continue
+		}
+
+		// Keep the original text up to the SOA keyword, including any
+		// leading whitespace that makes the owner inherited
+		pos := 0
+		for j := 0; j <= idx; j++ {
+			pos = strings.Index(code[pos:], fields[j]) + pos + len(fields[j])
+		}
+		prefix = code[:pos]
+
+		depth := 0
+		for k := i; k < len(lines); k++ {
+			c := stripZoneComment(lines[k])
+			depth += strings.Count(c, "(") - strings.Count(c, ")")
+			if depth <= 0 {
+				return i, k, prefix
+			}
+		}
+		return i, len(lines) - 1, prefix
+	}
+	return -1, -1, ""
+}
+
+// stripZoneComment removes a ";" comment from a zone file line, ignoring
+// semicolons inside quoted strings.
+func stripZoneComment(line string) string {
+	inQuote := false
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case '"':
+			inQuote = !inQuote
+		case ';':
+			if !inQuote {
+				return line[:i]
+			}
+		}
+	}
+	return line
+}
+
+// DelegationSigner returns the DS records (SHA-256, plus SHA-1 if requested)
+// and the DNSKEYs for every key-signing key (SEP flag set) in a signed zone.
+func (m *ZoneManager) DelegationSigner(domain string, withSHA1 bool) (ds []*dns.DS, keys []*dns.DNSKEY, err error) {
+	raw, err := m.ReadRaw(domain)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	origin := dns.Fqdn(domain)
+	parser := dns.NewZoneParser(strings.NewReader(raw), origin, "")
+	for rr, ok := parser.Next(); ok; rr, ok = parser.Next() {
+		key, isKey := rr.(*dns.DNSKEY)
+		if !isKey || key.Header().Name != origin || key.Flags&dns.SEP == 0 {
+			continue
+		}
+		keys = append(keys, key)
+		if d := key.ToDS(dns.SHA256); d != nil {
+			ds = append(ds, d)
+		}
+		if withSHA1 {
+			if d := key.ToDS(dns.SHA1); d != nil {
+				ds = append(ds, d)
+			}
+		}
+	}
+	if err := parser.Err(); err != nil {
+		return nil, nil, fmt.Errorf("zone parse error: %w", err)
+	}
+
+	if len(keys) == 0 {
+		return nil, nil, fmt.Errorf("no key-signing DNSKEY found at the zone apex; is the zone signed?")
+	}
+	return ds, keys, nil
 }
 
 // Validate checks that the content is a valid zone file with an SOA record.
@@ -273,10 +937,12 @@ func (m *ZoneManager) Validate(domain, content string) error {
 	parser := dns.NewZoneParser(strings.NewReader(content), origin, "")
 
 	hasSOA := false
+	var rrs []dns.RR
 	for rr, ok := parser.Next(); ok; rr, ok = parser.Next() {
 		if _, isSOA := rr.(*dns.SOA); isSOA {
 			hasSOA = true
 		}
+		rrs = append(rrs, rr)
 	}
 
 	if err := parser.Err(); err != nil {
@@ -287,20 +953,59 @@ func (m *ZoneManager) Validate(domain, content string) error {
 		return fmt.Errorf("zone file must contain an SOA record")
 	}
 
+	return checkCNAMEConflicts(rrs, origin)
+}
+
+// checkCNAMEConflicts rejects a CNAME at the zone apex and a CNAME sharing
+// its name with any other record (RFC 1034 section 3.6.2); CoreDNS refuses
+// to load such zones. DNSSEC records may coexist with a CNAME.
+func checkCNAMEConflicts(rrs []dns.RR, origin string) error {
+	cnames := make(map[string]bool)
+	for _, rr := range rrs {
+		if rr.Header().Rrtype != dns.TypeCNAME {
+			continue
+		}
+		name := strings.ToLower(rr.Header().Name)
+		if name == strings.ToLower(origin) {
+			return fmt.Errorf("CNAME at the zone apex (@) is not allowed; the apex already has SOA and NS records")
+		}
+		if cnames[name] {
+			return fmt.Errorf("%s has more than one CNAME record", relativeName(rr.Header().Name, origin))
+		}
+		cnames[name] = true
+	}
+
+	for _, rr := range rrs {
+		switch rr.Header().Rrtype {
+		case dns.TypeCNAME, dns.TypeRRSIG, dns.TypeNSEC, dns.TypeNSEC3:
+			continue
+		}
+		if cnames[strings.ToLower(rr.Header().Name)] {
+			return fmt.Errorf("CNAME %s conflicts with the %s record at the same name; a CNAME must be the only record for its name",
+				relativeName(rr.Header().Name, origin), dns.TypeToString[rr.Header().Rrtype])
+		}
+	}
 	return nil
 }
 
+// ParseZone parses arbitrary zone content against the given origin without
+// reading or writing any file. Records before a parse error are returned
+// alongside it.
+func ParseZone(origin, content string) ([]Record, *SOAData, error) {
+	if strings.TrimSpace(origin) == "" {
+		return nil, nil, fmt.Errorf("origin cannot be empty")
+	}
+	return parseZoneFile(content, dns.Fqdn(origin))
+}
+
 // parseZoneFile parses a zone file and returns records and SOA data.
-func parseZoneFile(content, origin string) ([]Record, *SOAData) {
+func parseZoneFile(content, origin string) ([]Record, *SOAData, error) {
 	parser := dns.NewZoneParser(strings.NewReader(content), origin, "")
 
 	var records []Record
 	var soa *SOAData
 
 	for rr, ok := parser.Next(); ok; rr, ok = parser.Next() {
-		name := relativeName(rr.Header().Name, origin)
-		ttl := rr.Header().Ttl
-
 		switch v := rr.(type) {
 		case *dns.SOA:
 			soa = &SOAData{
@@ -314,55 +1019,51 @@ func parseZoneFile(content, origin string) ([]Record, *SOAData) {
 			}
 		case *dns.NS:
 			// Skip apex NS records (required, not user-editable)
-			if name == "@" {
+			if relativeName(v.Hdr.Name, origin) == "@" {
 				continue
 			}
 			records = append(records, Record{
-				Name:  name,
+				Name:  relativeName(v.Hdr.Name, origin),
 				Type:  TypeNS,
-				TTL:   ttl,
+				TTL:   v.Hdr.Ttl,
 				Value: v.Ns,
 			})
-		case *dns.A:
-			records = append(records, Record{
-				Name:  name,
-				Type:  TypeA,
-				TTL:   ttl,
-				Value: v.A.String(),
-			})
-		case *dns.AAAA:
-			records = append(records, Record{
-				Name:  name,
-				Type:  TypeAAAA,
-				TTL:   ttl,
-				Value: v.AAAA.String(),
-			})
-		case *dns.CNAME:
-			records = append(records, Record{
-				Name:  name,
-				Type:  TypeCNAME,
-				TTL:   ttl,
-				Value: v.Target,
-			})
-		case *dns.MX:
-			records = append(records, Record{
-				Name:     name,
-				Type:     TypeMX,
-				TTL:      ttl,
-				Value:    v.Mx,
-				Priority: v.Preference,
-			})
-		case *dns.TXT:
-			records = append(records, Record{
-				Name:  name,
-				Type:  TypeTXT,
-				TTL:   ttl,
-				Value: strings.Join(v.Txt, " "),
-			})
+		default:
+			if rec, ok := recordFromRR(rr, origin); ok {
+				records = append(records, rec)
+			}
 		}
 	}
 
-	return records, soa
+	return records, soa, parser.Err()
+}
+
+// recordFromRR converts a parsed RR of one of the supported types into a
+// Record with a name relative to origin. SOA and unsupported types report
+// false.
+func recordFromRR(rr dns.RR, origin string) (Record, bool) {
+	name := relativeName(rr.Header().Name, origin)
+	ttl := rr.Header().Ttl
+
+	switch v := rr.(type) {
+	case *dns.NS:
+		return Record{Name: name, Type: TypeNS, TTL: ttl, Value: v.Ns}, true
+	case *dns.A:
+		return Record{Name: name, Type: TypeA, TTL: ttl, Value: v.A.String()}, true
+	case *dns.AAAA:
+		return Record{Name: name, Type: TypeAAAA, TTL: ttl, Value: v.AAAA.String()}, true
+	case *dns.CNAME:
+		return Record{Name: name, Type: TypeCNAME, TTL: ttl, Value: v.Target}, true
+	case *dns.MX:
+		return Record{Name: name, Type: TypeMX, TTL: ttl, Value: v.Mx, Priority: v.Preference}, true
+	case *dns.TXT:
+		return Record{Name: name, Type: TypeTXT, TTL: ttl, Value: strings.Join(v.Txt, "")}, true
+	case *dns.PTR:
+		return Record{Name: name, Type: TypePTR, TTL: ttl, Value: v.Ptr}, true
+	case *dns.CAA:
+		return Record{Name: name, Type: TypeCAA, TTL: ttl, Value: v.Value, Flag: v.Flag, Tag: v.Tag}, true
+	}
+	return Record{}, false
 }
 
 // relativeName converts an FQDN to a name relative to the origin.
@@ -395,17 +1096,64 @@ func formatRecord(rec Record) string {
 	case TypeMX:
 		return fmt.Sprintf("%s %sIN MX %d %s", rec.Name, ttlStr, rec.Priority, rec.Value)
 	case TypeTXT:
-		// Ensure TXT values are quoted
+		// Values already in quoted form are written as given
 		val := rec.Value
 		if !strings.HasPrefix(val, `"`) {
-			val = `"` + val + `"`
+			val = quoteTXT(val)
 		}
 		return fmt.Sprintf("%s %sIN TXT %s", rec.Name, ttlStr, val)
+	case TypeCAA:
+		return fmt.Sprintf("%s %sIN CAA %d %s %q", rec.Name, ttlStr, rec.Flag, rec.Tag, strings.Trim(rec.Value, `"`))
 	default:
 		return fmt.Sprintf("%s %sIN %s %s", rec.Name, ttlStr, rec.Type, rec.Value)
 	}
 }
 
+// hasRecord reports whether content already holds rec, comparing the name,
+// type, and value the way matchesRecord does. TTL and MX priority are not
+// compared.
+func hasRecord(content, origin string, rec Record) bool {
+	name := relativeName(qualifyName(rec.Name, origin), origin)
+	value := rec.Value
+	switch {
+	case rec.Type == TypeCAA:
+		value = fmt.Sprintf("%d %s %s", rec.Flag, rec.Tag, strings.Trim(value, `"`))
+	case rec.Type == TypeTXT:
+		value = strings.Trim(value, `"`)
+	case hasTargetName(rec.Type):
+		// matchesRecord sees parsed, fully qualified targets
+		value = qualifyName(value, origin)
+	}
+	for _, line := range strings.Split(content, "\n") {
+		if matchesRecord(line, name, rec.Type, value, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// maxTXTChunk is the longest character-string a TXT record can hold.
+const maxTXTChunk = 255
+
+// quoteTXT writes a TXT value as quoted character-strings, splitting it
+// into 255-byte chunks on one line ("chunk1" "chunk2"). Resolvers and
+// parseZoneFile join the chunks back together, so long DKIM keys survive
+// a round trip unchanged.
+func quoteTXT(value string) string {
+	var chunks []string
+	for len(value) > maxTXTChunk {
+		chunks = append(chunks, value[:maxTXTChunk])
+		value = value[maxTXTChunk:]
+	}
+	chunks = append(chunks, value)
+
+	escape := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+	for i, c := range chunks {
+		chunks[i] = `"` + escape.Replace(c) + `"`
+	}
+	return strings.Join(chunks, " ")
+}
+
 // matchesRecord checks if a zone file line matches the given record parameters.
 func matchesRecord(line, name string, rtype RecordType, value, origin string) bool {
 	trimmed := strings.TrimSpace(line)
@@ -435,17 +1183,47 @@ func matchesRecord(line, name string, rtype RecordType, value, origin string) bo
 	case *dns.MX:
 		return rtype == TypeMX && (v.Mx == value || v.Mx == dns.Fqdn(value))
 	case *dns.TXT:
-		return rtype == TypeTXT && strings.Join(v.Txt, " ") == value
+		return rtype == TypeTXT && strings.Join(v.Txt, "") == value
 	case *dns.NS:
 		return rtype == TypeNS && (v.Ns == value || v.Ns == dns.Fqdn(value))
+	case *dns.PTR:
+		return rtype == TypePTR && (v.Ptr == value || v.Ptr == dns.Fqdn(value))
+	case *dns.CAA:
+		// Accept the full "flag tag value" form to tell apart records that
+		// share a CA, e.g. an issue and an issuewild for letsencrypt.org
+		return rtype == TypeCAA && (v.Value == value || fmt.Sprintf("%d %s %s", v.Flag, v.Tag, v.Value) == value)
 	}
 
 	return false
 }
 
 // incrementSOASerial finds the SOA serial in the content and increments it.
-// Uses YYYYMMDDNN format. If today's date matches, increments NN. Otherwise resets to today+01.
-func incrementSOASerial(content string) string {
+// In the default YYYYMMDDNN format, if today's date matches it increments
+// NN, otherwise it resets to today+01. After NN=99 it rolls over into the
+// next day's date (YYYYMMDD+1 with NN=00), and a serial already ahead of
+// today keeps counting up from where it is. With EpochSerials the serial
+// becomes the current Unix time, or the old serial plus one if that is
+// larger. Either way secondaries always see an increase.
+func (m *ZoneManager) incrementSOASerial(content string) string {
+	return replaceSOASerial(content, func(old string) string {
+		if m.opts.EpochSerials {
+			return nextEpochSerial(old, time.Now())
+		}
+		return nextDateSerial(old, time.Now())
+	})
+}
+
+// initialSerial is the serial for a newly created zone.
+func (m *ZoneManager) initialSerial() string {
+	if m.opts.EpochSerials {
+		return strconv.FormatInt(time.Now().Unix(), 10)
+	}
+	return time.Now().Format("20060102") + "01"
+}
+
+// replaceSOASerial swaps the YYYYMMDDNN SOA serial in content for
+// next(serial). Content without a recognizable serial is returned as is.
+func replaceSOASerial(content string, next func(old string) string) string {
 	// Match serial line in SOA record: digits followed by optional whitespace and ; serial comment
 	re := regexp.MustCompile(`(\s+)(\d{10})(\s*;\s*serial)`)
 	match := re.FindStringSubmatch(content)
@@ -458,26 +1236,98 @@ func incrementSOASerial(content string) string {
 		}
 	}
 
-	oldSerial := match[2]
-	today := time.Now().Format("20060102")
+	return strings.Replace(content, match[0], match[1]+next(match[2])+match[3], 1)
+}
+
+// nextDateSerial returns the YYYYMMDDNN serial following old on day now.
+func nextDateSerial(old string, now time.Time) string {
+	oldSerial, _ := strconv.ParseUint(old, 10, 64)
+	todaySerial, _ := strconv.ParseUint(now.Format("20060102")+"01", 10, 64)
+
+	next := oldSerial + 1
+	if oldSerial%100 == 99 {
+		// NN would overflow past 99: move on to the next calendar date
+		if day, err := time.Parse("20060102", old[:8]); err == nil {
+			next, _ = strconv.ParseUint(day.AddDate(0, 0, 1).Format("20060102")+"00", 10, 64)
+		}
+	}
+	if next < todaySerial {
+		next = todaySerial
+	}
+	return fmt.Sprintf("%010d", next)
+}
 
-	var newSerial string
-	if strings.HasPrefix(oldSerial, today) {
-		// Same day, increment the sequence number
-		nn, _ := strconv.Atoi(oldSerial[8:])
-		nn++
-		newSerial = fmt.Sprintf("%s%02d", today, nn)
+// nextEpochSerial returns the Unix-time serial following old at now.
+func nextEpochSerial(old string, now time.Time) string {
+	oldSerial, _ := strconv.ParseUint(old, 10, 64)
+	next := uint64(now.Unix())
+	if next <= oldSerial {
+		next = oldSerial + 1
+	}
+	return strconv.FormatUint(next, 10)
+}
+
+// writeFile is the single write path for zone files.
+func (m *ZoneManager) writeFile(path, content string) error {
+	if m.opts.ManagedHeader {
+		content = setManagedHeader(content, time.Now())
+	}
+	prev, hadPrev, err := m.backup(path)
+	if err != nil {
+		return err
+	}
+	domain := strings.TrimPrefix(filepath.Base(path), zonePrefix)
+	m.cache.Delete(domain)
+	if err := atomicWrite(path, content); err != nil {
+		return err
+	}
+	if hadPrev {
+		m.prev.Store(domain, undoState{content: prev, version: ContentVersion(content)})
 	} else {
-		newSerial = today + "01"
+		m.prev.Delete(domain)
 	}
+	return nil
+}
 
-	return strings.Replace(content, match[0], match[1]+newSerial+match[3], 1)
+// setManagedHeader replaces any existing managed header at the top of the
+// content with a fresh one, so repeated saves don't stack headers.
+func setManagedHeader(content string, modified time.Time) string {
+	for strings.HasPrefix(content, managedHeader) {
+		_, rest, found := strings.Cut(content, "\n")
+		if !found {
+			rest = ""
+		}
+		content = rest
+	}
+	header := fmt.Sprintf("%s (last modified %s)\n", managedHeader, modified.UTC().Format(time.RFC3339))
+	return header + content
+}
+
+// CheckWritable verifies that files can be created in dir, so a read-only
+// mount is reported at startup rather than on the first save.
+func CheckWritable(dir string) error {
+	tmp, err := os.CreateTemp(dir, ".write-check-*.tmp")
+	if err != nil {
+		if isNotWritable(err) {
+			return fmt.Errorf("%s is not writable: check the volume mount/permissions", dir)
+		}
+		return err
+	}
+	tmp.Close()
+	return os.Remove(tmp.Name())
+}
+
+func isNotWritable(err error) bool {
+	return errors.Is(err, fs.ErrPermission) || errors.Is(err, syscall.EROFS)
 }
 
 func atomicWrite(path, content string) error {
 	dir := filepath.Dir(path)
 	tmp, err := os.CreateTemp(dir, ".zone-*.tmp")
 	if err != nil {
+		if isNotWritable(err) {
+			return fmt.Errorf("zone directory is not writable: check the volume mount/permissions of %s", dir)
+		}
 		return fmt.Errorf("failed to create temp file: %w", err)
 	}
 	tmpPath := tmp.Name()
@@ -487,6 +1337,13 @@ func atomicWrite(path, content string) error {
 		os.Remove(tmpPath)
 		return err
 	}
+	// Flush the content before the rename makes it visible, so a crash
+	// can't leave a truncated zone file in place of the old one
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to sync temp file: %w", err)
+	}
 	if err := tmp.Close(); err != nil {
 		os.Remove(tmpPath)
 		return err
@@ -501,5 +1358,24 @@ func atomicWrite(path, content string) error {
 		os.Remove(tmpPath)
 		return err
 	}
+	// The new content is in place; failing to make the rename durable
+	// doesn't make the write fail
+	if err := syncDir(dir); err != nil {
+		log.Printf("wrote %s, but %v", path, err)
+	}
+	return nil
+}
+
+// syncDir flushes a directory entry change such as a rename to disk.
+// Filesystems that can't sync directories are not treated as an error.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil && !errors.Is(err, syscall.EINVAL) && !errors.Is(err, syscall.ENOTSUP) {
+		return fmt.Errorf("failed to sync %s: %w", dir, err)
+	}
 	return nil
 }
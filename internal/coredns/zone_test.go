@@ -0,0 +1,315 @@
+package coredns
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// newTestZone creates a zone for domain in a temporary directory.
+func newTestZone(t *testing.T, domain string, opts ZoneOptions) *ZoneManager {
+	t.Helper()
+	m := NewZoneManager(t.TempDir(), opts)
+	if err := m.Create(domain); err != nil {
+		t.Fatalf("Create(%q): %v", domain, err)
+	}
+	return m
+}
+
+// parseTestZone parses the zone file for domain with miekg/dns.
+func parseTestZone(t *testing.T, m *ZoneManager, domain string) []dns.RR {
+	t.Helper()
+	raw, err := os.ReadFile(filepath.Join(m.dir, zonePrefix+domain))
+	if err != nil {
+		t.Fatal(err)
+	}
+	zp := dns.NewZoneParser(strings.NewReader(string(raw)), dns.Fqdn(domain), "")
+	var rrs []dns.RR
+	for rr, ok := zp.Next(); ok; rr, ok = zp.Next() {
+		rrs = append(rrs, rr)
+	}
+	if err := zp.Err(); err != nil {
+		t.Fatalf("parse zone: %v", err)
+	}
+	return rrs
+}
+
+func TestNextEpochSerial(t *testing.T) {
+	now := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC) // 1710504000
+	later := time.Date(2035, 1, 1, 0, 0, 0, 0, time.UTC) // 2051222400
+	tests := []struct {
+		name string
+		old  string
+		now  time.Time
+		want string
+	}{
+		{"behind now", "1710000000", now, "1710504000"},
+		{"ahead of now", "1710600000", now, "1710600001"},
+		{"equal to now", "1710504000", now, "1710504001"},
+		// Date serials are numerically larger than today's Unix time, so
+		// switching to epoch mode keeps counting up from them
+		{"from a date serial", "2024031505", now, "2024031506"},
+		{"from a date serial once Unix time passes it", "2024031505", later, "2051222400"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := nextEpochSerial(tt.old, tt.now)
+			if got != tt.want {
+				t.Errorf("nextEpochSerial(%q) = %q, want %q", tt.old, got, tt.want)
+			}
+			if got <= tt.old {
+				t.Errorf("nextEpochSerial(%q) = %q does not increase", tt.old, got)
+			}
+		})
+	}
+}
+
+func TestAddRecordConcurrent(t *testing.T) {
+	const domain = "example.com"
+	const n = 100
+	m := newTestZone(t, domain, ZoneOptions{})
+
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rec := Record{Name: fmt.Sprintf("host%d", i), Type: TypeA, Value: fmt.Sprintf("10.0.%d.%d", i/256, i%256)}
+			if err := m.AddRecord(domain, rec); err != nil {
+				errs <- fmt.Errorf("AddRecord(%s): %w", rec.Name, err)
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	seen := map[string]bool{}
+	for _, rr := range parseTestZone(t, m, domain) {
+		if a, ok := rr.(*dns.A); ok {
+			seen[a.Hdr.Name] = true
+		}
+	}
+	for i := range n {
+		if name := fmt.Sprintf("host%d.%s.", i, domain); !seen[name] {
+			t.Errorf("record %s missing from the zone file", name)
+		}
+	}
+	if len(seen) != n {
+		t.Errorf("zone has %d A records, want %d", len(seen), n)
+	}
+}
+
+func TestDeleteRemovesLockFile(t *testing.T) {
+	const domain = "example.com"
+	m := newTestZone(t, domain, ZoneOptions{})
+	if err := m.AddRecord(domain, Record{Name: "www", Type: TypeA, Value: "192.0.2.1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Delete(domain); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.AddRecord("missing.example", Record{Name: "www", Type: TypeA, Value: "192.0.2.1"}); err == nil {
+		t.Fatal("AddRecord on a missing zone succeeded")
+	}
+
+	entries, err := os.ReadDir(m.dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if strings.HasSuffix(e.Name(), ".lock") {
+			t.Errorf("lock file %s left behind", e.Name())
+		}
+	}
+}
+
+func TestUndo(t *testing.T) {
+	const domain = "example.com"
+	m := newTestZone(t, domain, ZoneOptions{})
+	if err := m.AddRecord(domain, Record{Name: "www", Type: TypeA, Value: "192.0.2.1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Undo(domain); err != nil {
+		t.Fatalf("Undo() right after a write: %v", err)
+	}
+	if raw, _ := m.ReadRaw(domain); strings.Contains(raw, "192.0.2.1") {
+		t.Error("Undo() left the added record in place")
+	}
+
+	// A change made outside the manager is not overwritten
+	if err := m.AddRecord(domain, Record{Name: "www", Type: TypeA, Value: "192.0.2.2"}); err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := m.ReadRaw(domain)
+	edited := raw + "mail IN A 192.0.2.25\n"
+	if err := os.WriteFile(m.filename(domain), []byte(edited), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Undo(domain); !errors.Is(err, ErrUndoConflict) {
+		t.Fatalf("Undo() after an outside edit = %v, want ErrUndoConflict", err)
+	}
+	if raw, _ := m.ReadRaw(domain); raw != edited {
+		t.Error("refused Undo() changed the zone file")
+	}
+
+	// Nothing is kept for a deleted zone
+	if err := m.Delete(domain); err != nil {
+		t.Fatal(err)
+	}
+	if m.CanUndo(domain) {
+		t.Error("CanUndo() after Delete() = true")
+	}
+}
+
+func TestNextDateSerial(t *testing.T) {
+	now := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		old  string
+		want string
+	}{
+		{"same day", "2024031503", "2024031504"},
+		{"earlier day", "2024031403", "2024031501"},
+		{"NN=99 rolls over to the next day", "2024031599", "2024031600"},
+		{"ahead of today", "2024040105", "2024040106"},
+		{"ahead of today at NN=99", "2024040199", "2024040200"},
+		{"non-date serial behind today", "0000000042", "2024031501"},
+		{"non-date serial at NN=99", "1234567899", "2024031501"},
+		{"non-date serial ahead of today", "3000131599", "3000131600"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := nextDateSerial(tt.old, now); got != tt.want {
+				t.Errorf("nextDateSerial(%q) = %q, want %q", tt.old, got, tt.want)
+			}
+		})
+	}
+
+	// The rollover follows the calendar, not the digits
+	leapDay := time.Date(2024, 2, 29, 12, 0, 0, 0, time.UTC)
+	if got := nextDateSerial("2024022999", leapDay); got != "2024030100" {
+		t.Errorf("nextDateSerial(%q) on leap day = %q, want %q", "2024022999", got, "2024030100")
+	}
+}
+
+func TestAddRecordLongTXT(t *testing.T) {
+	const domain = "example.com"
+	m := newTestZone(t, domain, ZoneOptions{})
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	value := "v=DKIM1; k=rsa; p=" + base64.StdEncoding.EncodeToString(der)
+	if len(value) <= maxTXTChunk {
+		t.Fatalf("test value is only %d bytes", len(value))
+	}
+
+	if err := m.AddRecord(domain, Record{Name: "default._domainkey", Type: TypeTXT, Value: value}); err != nil {
+		t.Fatalf("AddRecord: %v", err)
+	}
+
+	var found bool
+	for _, rr := range parseTestZone(t, m, domain) {
+		txt, ok := rr.(*dns.TXT)
+		if !ok || txt.Hdr.Name != "default._domainkey."+domain+"." {
+			continue
+		}
+		found = true
+		if got := strings.Join(txt.Txt, ""); got != value {
+			t.Errorf("TXT round trip changed the value:\n got %q\nwant %q", got, value)
+		}
+	}
+	if !found {
+		t.Fatal("TXT record not found in the zone file")
+	}
+}
+
+func BenchmarkExportNormalized(b *testing.B) {
+	const domain = "example.com"
+	const n = 100_000
+	m := NewZoneManager(b.TempDir(), ZoneOptions{})
+	content, err := m.PreviewCreate(domain)
+	if err != nil {
+		b.Fatal(err)
+	}
+	var sb strings.Builder
+	sb.WriteString(content)
+	for i := range n {
+		fmt.Fprintf(&sb, "host%d 300 IN A 10.%d.%d.%d\n", i, i>>16&0xff, i>>8&0xff, i&0xff)
+	}
+	if err := os.WriteFile(m.filename(domain), []byte(sb.String()), 0o644); err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		if err := m.ExportNormalized(domain, io.Discard); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestSortRecords(t *testing.T) {
+	records := []Record{
+		{Name: "www", Type: TypeNS, Value: "ns1"},
+		{Name: "mail", Type: TypeA, Value: "192.0.2.2"},
+		{Name: "www", Type: TypeA, Value: "192.0.2.3"},
+		{Name: "@", Type: TypeCAA, Value: "letsencrypt.org"},
+		{Name: "@", Type: TypeA, Value: "192.0.2.1"},
+		{Name: "www", Type: TypeA, Value: "192.0.2.4"},
+		{Name: "Mail", Type: TypeA, Value: "192.0.2.5"},
+		{Name: "1", Type: TypePTR, Value: "host"},
+		{Name: "@", Type: TypeMX, Value: "mail"},
+		{Name: "www", Type: TypeCNAME, Value: "web"},
+		{Name: "@", Type: TypeTXT, Value: "v=spf1 -all"},
+		{Name: "v6", Type: TypeAAAA, Value: "2001:db8::1"},
+	}
+	SortRecords(records)
+
+	var got []string
+	for _, r := range records {
+		got = append(got, string(r.Type)+" "+r.Name+" "+r.Value)
+	}
+	want := []string{
+		"A @ 192.0.2.1",
+		// Names compare case-insensitively; equal ones keep their order
+		"A mail 192.0.2.2",
+		"A Mail 192.0.2.5",
+		"A www 192.0.2.3",
+		"A www 192.0.2.4",
+		"AAAA v6 2001:db8::1",
+		"CNAME www web",
+		"MX @ mail",
+		"TXT @ v=spf1 -all",
+		"NS www ns1",
+		// Types without a fixed place follow alphabetically
+		"CAA @ letsencrypt.org",
+		"PTR 1 host",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("SortRecords() order:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
@@ -2,27 +2,80 @@ package docker
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
 	"strings"
+	"sync"
 	"time"
 
+	"simple-coredns-manager/internal/config"
+
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/client"
 )
 
+// pingInterval is how often the background monitor re-checks the daemon.
+const pingInterval = 30 * time.Second
+
+var errUnavailable = errors.New("Docker not available")
+
+// Options configures how the Client talks to the CoreDNS container.
+type Options struct {
+	// Binary is the CoreDNS executable inside the container. When empty,
+	// the container's entrypoint is used, falling back to /coredns.
+	Binary string
+	// Strategy is how ReloadCoreDNS makes CoreDNS pick up changes:
+	// config.ReloadSignal (the default), ReloadExec, or ReloadRestart.
+	Strategy string
+	// ReloadCommand is run inside the container by the exec strategy.
+	ReloadCommand []string
+}
+
 type Client struct {
 	containerName string
-	available     bool
-	cli           *client.Client
+	opts          Options
+
+	mu   sync.RWMutex
+	conn *conn // nil while the daemon is unreachable
+	info *CoreDNSInfo
+
+	// noValidate is the container whose CoreDNS was found to have no
+	// -validate flag, so it isn't asked again on every reload
+	noValidate  string
+	healthCache healthStatus
+
+	dialMu sync.Mutex // serializes reconnects
 }
 
-func NewClient(containerName string) *Client {
-	c := &Client{containerName: containerName}
+// healthStatus is the last healthcheck status read for a container.
+type healthStatus struct {
+	containerID string
+	health      string
+	checkedAt   time.Time
+}
+
+// conn is one dialed Docker client. It is closed only once every call that
+// acquired it has released it, so a reconnect can't pull it out from under
+// a request in flight.
+type conn struct {
+	cli   *client.Client
+	users sync.WaitGroup
+}
+
+func NewClient(containerName string, opts Options) *Client {
+	c := &Client{containerName: containerName, opts: opts}
+	c.connect()
+	go c.monitor()
+	return c
+}
 
+// connect (re-)dials the Docker daemon and updates availability.
+func (c *Client) connect() bool {
 	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
 	if err != nil {
-		c.available = false
-		return c
+		c.setClient(nil)
+		return false
 	}
 
 	// Quick ping to verify connectivity
@@ -30,29 +83,147 @@ func NewClient(containerName string) *Client {
 	defer cancel()
 	if _, err := cli.Ping(ctx); err != nil {
 		cli.Close()
-		c.available = false
-		return c
+		c.setClient(nil)
+		return false
 	}
 
-	c.cli = cli
-	c.available = true
-	return c
+	c.setClient(cli)
+	return true
+}
+
+// setClient swaps in a new client, or nil when the daemon is down. The old
+// client is closed in the background after its last user releases it.
+func (c *Client) setClient(cli *client.Client) {
+	var next *conn
+	if cli != nil {
+		next = &conn{cli: cli}
+	}
+
+	c.mu.Lock()
+	old := c.conn
+	c.conn = next
+	c.mu.Unlock()
+
+	if old != nil {
+		go func() {
+			old.users.Wait()
+			old.cli.Close()
+		}()
+	}
+}
+
+// acquire returns the current connection with a reference held, or nil when
+// the daemon is down. Callers must call release.
+func (c *Client) acquire() *conn {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.conn != nil {
+		c.conn.users.Add(1)
+	}
+	return c.conn
+}
+
+func (cn *conn) release() {
+	cn.users.Done()
+}
+
+// reconnect re-dials after a call on failed hit a connection error, unless
+// another call already replaced that connection.
+func (c *Client) reconnect(failed *conn) bool {
+	c.dialMu.Lock()
+	defer c.dialMu.Unlock()
+
+	c.mu.RLock()
+	current := c.conn
+	c.mu.RUnlock()
+	if current != failed {
+		return current != nil
+	}
+	return c.connect()
+}
+
+// monitor periodically pings the daemon so availability recovers after a
+// Docker restart without restarting the manager.
+func (c *Client) monitor() {
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		cn := c.acquire()
+		if cn != nil {
+			ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+			_, err := cn.cli.Ping(ctx)
+			cancel()
+			cn.release()
+			if err == nil {
+				continue
+			}
+			log.Printf("Docker ping failed, reconnecting: %v", err)
+		}
+		if c.reconnect(cn) && cn == nil {
+			log.Println("Docker socket reconnected")
+		}
+	}
+}
+
+// withClient runs fn against the current client, re-dialing once if the
+// call fails with a connection error. While the daemon is down it fails
+// fast; the monitor notices when it comes back.
+func (c *Client) withClient(fn func(cli *client.Client) error) error {
+	cn := c.acquire()
+	if cn == nil {
+		return errUnavailable
+	}
+	err := fn(cn.cli)
+	cn.release()
+	if err == nil || !client.IsErrConnectionFailed(err) {
+		return err
+	}
+
+	if !c.reconnect(cn) {
+		return fmt.Errorf("%w: %v", errUnavailable, err)
+	}
+	cn = c.acquire()
+	if cn == nil {
+		return fmt.Errorf("%w: %v", errUnavailable, err)
+	}
+	defer cn.release()
+	return fn(cn.cli)
 }
 
 func (c *Client) Available() bool {
-	return c.available
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.conn != nil
 }
 
-func (c *Client) FindContainer() (status string, containerID string, err error) {
-	if !c.available {
-		return "", "", fmt.Errorf("Docker not available")
+// FindContainer looks up the CoreDNS container by name. health is the
+// Docker healthcheck status ("healthy", "unhealthy", "starting"), empty when
+// the container has no healthcheck.
+func (c *Client) FindContainer() (status, health, containerID string, err error) {
+	status, containerID, err = c.findContainer()
+	if err != nil || containerID == "" {
+		return status, "", containerID, err
 	}
+	return status, c.health(containerID), containerID, nil
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+// findContainer looks up the CoreDNS container by name in one list call,
+// for callers that don't need its health.
+func (c *Client) findContainer() (status, containerID string, err error) {
+	var containers []container.Summary
+	err = c.withClient(func(cli *client.Client) error {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
 
-	containers, err := c.cli.ContainerList(ctx, container.ListOptions{All: true})
+		var listErr error
+		containers, listErr = cli.ContainerList(ctx, container.ListOptions{All: true})
+		return listErr
+	})
 	if err != nil {
+		if errors.Is(err, errUnavailable) {
+			return "", "", err
+		}
 		return "", "", fmt.Errorf("failed to list containers: %w", err)
 	}
 
@@ -69,12 +240,48 @@ func (c *Client) FindContainer() (status string, containerID string, err error)
 	return "", "", nil
 }
 
-func (c *Client) ReloadCoreDNS() error {
-	if !c.available {
-		return fmt.Errorf("Docker not available")
+// healthTTL is how long a container's healthcheck status is reused before
+// it is inspected again.
+const healthTTL = 10 * time.Second
+
+// health returns the healthcheck status of a container, inspecting it at
+// most once per healthTTL. Failures are logged and reported as no
+// healthcheck, since the state from the list is still useful.
+func (c *Client) health(containerID string) string {
+	c.mu.RLock()
+	cached := c.healthCache
+	c.mu.RUnlock()
+	if cached.containerID == containerID && time.Since(cached.checkedAt) < healthTTL {
+		return cached.health
+	}
+
+	var health string
+	err := c.withClient(func(cli *client.Client) error {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		inspect, err := cli.ContainerInspect(ctx, containerID)
+		if err != nil {
+			return err
+		}
+		if inspect.State != nil && inspect.State.Health != nil {
+			health = inspect.State.Health.Status
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("docker: failed to inspect container %.12s for its healthcheck: %v", containerID, err)
+		return ""
 	}
 
-	_, containerID, err := c.FindContainer()
+	c.mu.Lock()
+	c.healthCache = healthStatus{containerID: containerID, health: health, checkedAt: time.Now()}
+	c.mu.Unlock()
+	return health
+}
+
+func (c *Client) ReloadCoreDNS() error {
+	_, containerID, err := c.findContainer()
 	if err != nil {
 		return err
 	}
@@ -82,9 +289,29 @@ func (c *Client) ReloadCoreDNS() error {
 		return fmt.Errorf("CoreDNS container '%s' not found", c.containerName)
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	// SIGUSR1 triggers CoreDNS to reload its configuration
-	return c.cli.ContainerKill(ctx, containerID, "SIGUSR1")
+	return c.withClient(func(cli *client.Client) error {
+		switch c.opts.Strategy {
+		case config.ReloadExec:
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			stdout, stderr, code, err := execRun(ctx, cli, containerID, c.opts.ReloadCommand...)
+			if err != nil {
+				return fmt.Errorf("failed to run reload command: %w", err)
+			}
+			if code != 0 {
+				output := strings.TrimSpace(stderr + "\n" + stdout)
+				return fmt.Errorf("reload command exited with code %d: %s", code, output)
+			}
+			return nil
+		case config.ReloadRestart:
+			ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+			defer cancel()
+			return cli.ContainerRestart(ctx, containerID, container.StopOptions{})
+		default:
+			// SIGUSR1 triggers CoreDNS to reload its configuration
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			return cli.ContainerKill(ctx, containerID, "SIGUSR1")
+		}
+	})
 }
@@ -0,0 +1,216 @@
+package docker
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// CoreDNSInfo describes the CoreDNS build running in the container.
+type CoreDNSInfo struct {
+	ContainerID string
+	Version     string   // e.g. "CoreDNS-1.11.1", empty if unknown
+	Plugins     []string // plugins compiled into the binary, empty if unknown
+}
+
+// versionLabel is the OCI image label official CoreDNS images carry.
+const versionLabel = "org.opencontainers.image.version"
+
+// CoreDNSInfo asks the CoreDNS binary in the container for its version and
+// compiled-in plugins. Results are cached per container ID, so a recreated
+// container (e.g. after an image upgrade) is queried again. When exec is
+// unavailable, the version falls back to the image's OCI version label.
+func (c *Client) CoreDNSInfo() (*CoreDNSInfo, error) {
+	_, containerID, err := c.findContainer()
+	if err != nil {
+		return nil, err
+	}
+	if containerID == "" {
+		return nil, fmt.Errorf("CoreDNS container '%s' not found", c.containerName)
+	}
+
+	c.mu.RLock()
+	cached := c.info
+	c.mu.RUnlock()
+	if cached != nil && cached.ContainerID == containerID {
+		return cached, nil
+	}
+
+	info := &CoreDNSInfo{ContainerID: containerID}
+	err = c.withClient(func(cli *client.Client) error {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		inspect, err := cli.ContainerInspect(ctx, containerID)
+		if err != nil {
+			return err
+		}
+		binary := c.binary(inspect)
+		if inspect.Config != nil {
+			info.Version = inspect.Config.Labels[versionLabel]
+		}
+
+		if out, err := execOutput(ctx, cli, containerID, binary, "-version"); err == nil {
+			if line, _, _ := strings.Cut(strings.TrimSpace(out), "\n"); line != "" {
+				info.Version = line
+			}
+		}
+		if out, err := execOutput(ctx, cli, containerID, binary, "-plugins"); err == nil {
+			info.Plugins = parsePluginList(out)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Only cache complete answers; partial ones are retried next time
+	if info.Version != "" && len(info.Plugins) > 0 {
+		c.mu.Lock()
+		c.info = info
+		c.mu.Unlock()
+	}
+	return info, nil
+}
+
+// binary returns the CoreDNS executable: the configured one, else the
+// container's entrypoint, else /coredns as in the official image.
+func (c *Client) binary(inspect container.InspectResponse) string {
+	if c.opts.Binary != "" {
+		return c.opts.Binary
+	}
+	if inspect.Config != nil && len(inspect.Config.Entrypoint) > 0 {
+		return inspect.Config.Entrypoint[0]
+	}
+	return "/coredns"
+}
+
+// execOutput runs a command in the container and returns its stdout.
+func execOutput(ctx context.Context, cli *client.Client, containerID string, cmd ...string) (string, error) {
+	stdout, _, code, err := execRun(ctx, cli, containerID, cmd...)
+	if err != nil {
+		return "", err
+	}
+	if code != 0 {
+		return "", fmt.Errorf("%s exited with code %d", strings.Join(cmd, " "), code)
+	}
+	return stdout, nil
+}
+
+// execRun runs a command in the container and returns its output and exit
+// code. A non-zero exit code is not an error.
+func execRun(ctx context.Context, cli *client.Client, containerID string, cmd ...string) (stdout, stderr string, exitCode int, err error) {
+	exec, err := cli.ContainerExecCreate(ctx, containerID, container.ExecOptions{
+		Cmd:          cmd,
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		return "", "", 0, err
+	}
+	resp, err := cli.ContainerExecAttach(ctx, exec.ID, container.ExecAttachOptions{})
+	if err != nil {
+		return "", "", 0, err
+	}
+	defer resp.Close()
+
+	var out, errOut bytes.Buffer
+	if _, err := stdcopy.StdCopy(&out, &errOut, resp.Reader); err != nil {
+		return "", "", 0, err
+	}
+
+	result, err := cli.ContainerExecInspect(ctx, exec.ID)
+	if err != nil {
+		return "", "", 0, err
+	}
+	return out.String(), errOut.String(), result.ExitCode, nil
+}
+
+// parsePluginList extracts DNS plugin names from `coredns -plugins`, which
+// lists them as "dns.<name>" under "Other plugins:".
+func parsePluginList(out string) []string {
+	var plugins []string
+	for _, line := range strings.Split(out, "\n") {
+		if name, ok := strings.CutPrefix(strings.TrimSpace(line), "dns."); ok && name != "" {
+			plugins = append(plugins, name)
+		}
+	}
+	sort.Strings(plugins)
+	return plugins
+}
+
+// RunningCorefile reads the Corefile from inside the CoreDNS container. The
+// path comes from the container's -conf argument, defaulting to Corefile in
+// its working directory as CoreDNS does. The file is copied out through the
+// archive API, which works on images without a shell or cat.
+func (c *Client) RunningCorefile() (content, path string, err error) {
+	_, containerID, err := c.findContainer()
+	if err != nil {
+		return "", "", err
+	}
+	if containerID == "" {
+		return "", "", fmt.Errorf("CoreDNS container '%s' not found", c.containerName)
+	}
+
+	err = c.withClient(func(cli *client.Client) error {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		inspect, err := cli.ContainerInspect(ctx, containerID)
+		if err != nil {
+			return err
+		}
+		path = corefileArg(inspect.Args)
+		if !strings.HasPrefix(path, "/") {
+			dir := "/"
+			if inspect.Config != nil && inspect.Config.WorkingDir != "" {
+				dir = inspect.Config.WorkingDir
+			}
+			path = strings.TrimSuffix(dir, "/") + "/" + path
+		}
+
+		rc, _, err := cli.CopyFromContainer(ctx, containerID, path)
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+
+		tr := tar.NewReader(rc)
+		if _, err := tr.Next(); err != nil {
+			return fmt.Errorf("reading %s from container: %w", path, err)
+		}
+		data, err := io.ReadAll(io.LimitReader(tr, 1<<20))
+		if err != nil {
+			return err
+		}
+		content = string(data)
+		return nil
+	})
+	return content, path, err
+}
+
+// corefileArg returns the value of -conf in CoreDNS's arguments, written
+// with one or two dashes as Go's flag package allows.
+func corefileArg(args []string) string {
+	for i, arg := range args {
+		if !strings.HasPrefix(arg, "--") {
+			arg = "-" + arg
+		}
+		if v, ok := strings.CutPrefix(arg, "--conf="); ok {
+			return v
+		}
+		if arg == "--conf" && i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+	return "Corefile"
+}
@@ -0,0 +1,76 @@
+package docker
+
+import (
+	"sync"
+	"time"
+)
+
+// Status is a snapshot of the CoreDNS container state.
+type Status struct {
+	State       string    `json:"state"`
+	Health      string    `json:"health,omitempty"` // healthcheck status, if the container has one
+	ContainerID string    `json:"container_id"`
+	DockerOK    bool      `json:"docker_ok"`
+	Error       string    `json:"error,omitempty"`
+	CheckedAt   time.Time `json:"checked_at"`
+}
+
+// StatusCache serves the last known container status and refreshes it in
+// the background once it is older than the TTL, so page loads don't wait on
+// the Docker API.
+type StatusCache struct {
+	client *Client
+	ttl    time.Duration
+
+	mu         sync.Mutex
+	status     Status
+	refreshing bool
+}
+
+func NewStatusCache(client *Client, ttl time.Duration) *StatusCache {
+	return &StatusCache{client: client, ttl: ttl}
+}
+
+// Get returns the cached status. Only the very first call blocks on Docker;
+// afterwards a stale value is returned while a refresh runs in the background.
+func (s *StatusCache) Get() Status {
+	s.mu.Lock()
+	if s.status.CheckedAt.IsZero() {
+		s.mu.Unlock()
+		return s.refresh()
+	}
+	status := s.status
+	if time.Since(status.CheckedAt) >= s.ttl && !s.refreshing {
+		s.refreshing = true
+		go s.refresh()
+	}
+	s.mu.Unlock()
+	return status
+}
+
+// Invalidate forces the next Get to refresh, e.g. after a reload.
+func (s *StatusCache) Invalidate() {
+	s.mu.Lock()
+	s.status.CheckedAt = time.Time{}
+	s.mu.Unlock()
+}
+
+func (s *StatusCache) refresh() Status {
+	state, health, containerID, err := s.client.FindContainer()
+	status := Status{
+		State:       state,
+		Health:      health,
+		ContainerID: containerID,
+		DockerOK:    err == nil,
+		CheckedAt:   time.Now(),
+	}
+	if err != nil {
+		status.Error = err.Error()
+	}
+
+	s.mu.Lock()
+	s.status = status
+	s.refreshing = false
+	s.mu.Unlock()
+	return status
+}
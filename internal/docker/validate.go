@@ -0,0 +1,75 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/client"
+)
+
+// ErrValidateUnsupported is returned by ValidateConfig when the CoreDNS
+// binary in the container has no -validate flag, as stock CoreDNS builds
+// don't.
+var ErrValidateUnsupported = errors.New("CoreDNS binary does not support -validate")
+
+// ValidationError carries the output of a failed `coredns -validate`.
+type ValidationError struct {
+	ExitCode int
+	Output   string
+}
+
+func (e *ValidationError) Error() string {
+	if e.Output == "" {
+		return fmt.Sprintf("coredns -validate exited with code %d", e.ExitCode)
+	}
+	return fmt.Sprintf("coredns -validate exited with code %d: %s", e.ExitCode, e.Output)
+}
+
+// ValidateConfig runs `coredns -validate` inside the CoreDNS container
+// against the Corefile it was started with, so a broken config is caught
+// before CoreDNS is told to reload it.
+func (c *Client) ValidateConfig() error {
+	_, containerID, err := c.findContainer()
+	if err != nil {
+		return err
+	}
+	if containerID == "" {
+		return fmt.Errorf("CoreDNS container '%s' not found", c.containerName)
+	}
+	c.mu.RLock()
+	unsupported := c.noValidate == containerID
+	c.mu.RUnlock()
+	if unsupported {
+		return ErrValidateUnsupported
+	}
+
+	return c.withClient(func(cli *client.Client) error {
+		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		defer cancel()
+
+		inspect, err := cli.ContainerInspect(ctx, containerID)
+		if err != nil {
+			return err
+		}
+		cmd := []string{c.binary(inspect), "-conf", corefileArg(inspect.Args), "-validate"}
+		stdout, stderr, code, err := execRun(ctx, cli, containerID, cmd...)
+		if err != nil {
+			return fmt.Errorf("failed to run %s: %w", strings.Join(cmd, " "), err)
+		}
+		if code == 0 {
+			return nil
+		}
+
+		output := strings.TrimSpace(stderr + "\n" + stdout)
+		if strings.Contains(output, "flag provided but not defined") {
+			c.mu.Lock()
+			c.noValidate = containerID
+			c.mu.Unlock()
+			return ErrValidateUnsupported
+		}
+		return &ValidationError{ExitCode: code, Output: output}
+	})
+}
@@ -0,0 +1,259 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/filesystem"
+)
+
+// Repo commits changes to the files the manager writes into an existing git
+// repository, in-process with go-git. A nil *Repo commits nothing.
+type Repo struct {
+	repo   *gogit.Repository
+	dir    string
+	gitDir string   // where index.lock is taken
+	paths  []string // paths, relative to dir, that commits are limited to
+
+	// committer is the configured user, or the manager itself when the
+	// repository has no user.email configured
+	committer object.Signature
+
+	mu sync.Mutex
+}
+
+// Open checks that dir is inside a git work tree and returns a Repo that
+// commits changes under paths. Paths outside the repository are ignored.
+func Open(dir string, paths ...string) (*Repo, error) {
+	repo, err := gogit.PlainOpenWithOptions(dir, &gogit.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return nil, fmt.Errorf("%s is not a git repository: %w", dir, err)
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		return nil, fmt.Errorf("%s has no work tree: %w", dir, err)
+	}
+	r := &Repo{repo: repo, dir: wt.Filesystem.Root(), gitDir: filepath.Join(wt.Filesystem.Root(), ".git")}
+	if st, ok := repo.Storer.(*filesystem.Storage); ok {
+		r.gitDir = st.Filesystem().Root()
+	}
+	if resolved, err := filepath.EvalSymlinks(r.dir); err == nil {
+		r.dir = resolved
+	}
+
+	for _, p := range paths {
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			continue
+		}
+		if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+			abs = resolved
+		}
+		rel, err := filepath.Rel(r.dir, abs)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+			continue
+		}
+		r.paths = append(r.paths, filepath.ToSlash(rel))
+	}
+	if len(r.paths) == 0 {
+		return nil, fmt.Errorf("none of %s is inside the git repository at %s", strings.Join(paths, ", "), r.dir)
+	}
+
+	r.committer = object.Signature{Name: "simple-coredns-manager", Email: "simple-coredns-manager@localhost"}
+	if cfg, err := repo.ConfigScoped(config.GlobalScope); err == nil && cfg.User.Email != "" {
+		r.committer = object.Signature{Name: cfg.User.Name, Email: cfg.User.Email}
+	}
+	return r, nil
+}
+
+// Dir returns the top of the work tree.
+func (r *Repo) Dir() string {
+	if r == nil {
+		return ""
+	}
+	return r.dir
+}
+
+// Commit commits the current content of files, which were just written or
+// removed, with the given message, authored by user. Only those files go
+// into the commit: other changes in the work tree, and changes staged by
+// someone else, are left as they are. Files outside the repository's paths
+// and hidden files such as lock files are skipped. It does nothing when
+// none of the files changed.
+//
+// Like git itself, it holds index.lock while it works, so a git command run
+// in the same work tree at the same time fails instead of racing it.
+func (r *Repo) Commit(message, user string, files ...string) error {
+	if r == nil {
+		return nil
+	}
+	var changed []string
+	for _, f := range files {
+		if rel, ok := r.relative(f); ok && r.tracks(rel) && !slices.Contains(changed, rel) {
+			changed = append(changed, rel)
+		}
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	unlock, err := r.lockIndex()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	wt, err := r.repo.Worktree()
+	if err != nil {
+		return err
+	}
+	staged, err := r.repo.Storer.Index()
+	if err != nil {
+		return fmt.Errorf("failed to read the index: %w", err)
+	}
+	saved := make([]index.Entry, len(staged.Entries))
+	for i, e := range staged.Entries {
+		saved[i] = *e
+	}
+
+	// Start from HEAD so only our changes go into the commit
+	if _, err := r.repo.Head(); err == nil {
+		err = wt.Reset(&gogit.ResetOptions{Mode: gogit.MixedReset})
+	} else if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		err = r.repo.Storer.SetIndex(&index.Index{Version: staged.Version})
+	}
+	if err != nil {
+		return fmt.Errorf("failed to reset the index: %w", err)
+	}
+
+	err = r.commit(wt, changed, message, user)
+	if rerr := r.restoreIndex(saved, changed); err == nil {
+		err = rerr
+	}
+	return err
+}
+
+func (r *Repo) commit(wt *gogit.Worktree, changed []string, message, user string) error {
+	for _, rel := range changed {
+		var err error
+		if _, statErr := os.Lstat(filepath.Join(r.dir, filepath.FromSlash(rel))); statErr == nil {
+			_, err = wt.Add(rel)
+		} else if _, err = wt.Remove(rel); errors.Is(err, index.ErrEntryNotFound) {
+			err = nil // removed before it was ever committed
+		}
+		if err != nil {
+			return fmt.Errorf("failed to stage %s: %w", rel, err)
+		}
+	}
+
+	author := &object.Signature{Name: user, Email: user + "@simple-coredns-manager", When: time.Now()}
+	committer := r.committer
+	committer.When = author.When
+	_, err := wt.Commit(message, &gogit.CommitOptions{Author: author, Committer: &committer})
+	if errors.Is(err, gogit.ErrEmptyCommit) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to commit: %w", err)
+	}
+	return nil
+}
+
+// lockIndex creates index.lock the way git does, failing if another git
+// process holds it.
+func (r *Repo) lockIndex() (func(), error) {
+	path := filepath.Join(r.gitDir, "index.lock")
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o644)
+	if errors.Is(err, fs.ErrExist) {
+		return nil, fmt.Errorf("%s exists: another git process is running in the repository", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock the index: %w", err)
+	}
+	f.Close()
+	return func() { os.Remove(path) }, nil
+}
+
+// restoreIndex puts back the index as it was before the commit, except for
+// the committed files, which keep their entries as just committed.
+func (r *Repo) restoreIndex(saved []index.Entry, changed []string) error {
+	idx, err := r.repo.Storer.Index()
+	if err != nil {
+		return fmt.Errorf("failed to read the index: %w", err)
+	}
+	var entries []*index.Entry
+	for _, e := range idx.Entries {
+		if slices.Contains(changed, e.Name) {
+			entries = append(entries, e)
+		}
+	}
+	for i := range saved {
+		if !slices.Contains(changed, saved[i].Name) {
+			entries = append(entries, &saved[i])
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	idx.Entries = entries
+	if err := r.repo.Storer.SetIndex(idx); err != nil {
+		return fmt.Errorf("failed to write the index: %w", err)
+	}
+	return nil
+}
+
+// relative returns path relative to the top of the work tree, with forward
+// slashes, and false if it is outside it.
+func (r *Repo) relative(path string) (string, bool) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", false
+	}
+	// Resolve symlinks in the directory; the file itself may be gone
+	if dir, err := filepath.EvalSymlinks(filepath.Dir(abs)); err == nil {
+		abs = filepath.Join(dir, filepath.Base(abs))
+	}
+	rel, err := filepath.Rel(r.dir, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+// within reports whether a repository path is under one of our paths.
+func (r *Repo) within(path string) bool {
+	for _, p := range r.paths {
+		if p == "." || path == p || strings.HasPrefix(path, p+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// tracks reports whether changes to a repository path are ours to commit:
+// it is under one of our paths and neither it nor a directory above it is
+// hidden.
+func (r *Repo) tracks(path string) bool {
+	if !r.within(path) {
+		return false
+	}
+	for _, part := range strings.Split(path, "/") {
+		if strings.HasPrefix(part, ".") {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,140 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// writeFile writes content to name under dir, creating directories.
+func writeFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// headFiles returns the files in the HEAD commit and its message.
+func headFiles(t *testing.T, repo *gogit.Repository) (map[string]bool, *object.Commit) {
+	t.Helper()
+	ref, err := repo.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit, err := repo.CommitObject(ref.Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]bool{}
+	iter, err := commit.Files()
+	if err != nil {
+		t.Fatal(err)
+	}
+	iter.ForEach(func(f *object.File) error {
+		files[f.Name] = true
+		return nil
+	})
+	return files, commit
+}
+
+func TestCommit(t *testing.T) {
+	dir := t.TempDir()
+	repo, err := gogit.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wt, _ := repo.Worktree()
+	writeFile(t, dir, "README", "hello\n")
+	wt.Add("README")
+	if _, err := wt.Commit("initial", &gogit.CommitOptions{Author: &object.Signature{Name: "test", Email: "test@localhost"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := Open(dir, filepath.Join(dir, "zones"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Someone else's staged change outside the zone directory
+	writeFile(t, dir, "README", "changed\n")
+	wt.Add("README")
+
+	// Another user's unsaved-to-git change in the zone directory
+	writeFile(t, dir, "zones/db.example.org", "other\n")
+
+	writeFile(t, dir, "zones/db.example.com", "zone\n")
+	writeFile(t, dir, "zones/.db.example.com.lock", "")
+	zone := filepath.Join(dir, "zones/db.example.com")
+	if err := r.Commit("zone.create example.com", "alice", zone, filepath.Join(dir, "zones/.db.example.com.lock"), filepath.Join(dir, "README")); err != nil {
+		t.Fatal(err)
+	}
+
+	files, commit := headFiles(t, repo)
+	if !files["zones/db.example.com"] {
+		t.Error("zone file not committed")
+	}
+	if files["zones/.db.example.com.lock"] {
+		t.Error("hidden lock file committed")
+	}
+	if files["zones/db.example.org"] {
+		t.Error("a file the commit was not given was committed")
+	}
+	if commit.Author.Name != "alice" || commit.Message != "zone.create example.com" {
+		t.Errorf("commit by %s with message %q", commit.Author.Name, commit.Message)
+	}
+	if f, _ := commit.File("README"); f != nil {
+		if content, _ := f.Contents(); content != "hello\n" {
+			t.Errorf("README in commit = %q, want the unstaged-by-us original", content)
+		}
+	}
+	status, err := wt.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := status.File("README"); s.Staging != gogit.Modified {
+		t.Errorf("README staging status = %c, want it still staged", s.Staging)
+	}
+
+	// Nothing changed: no new commit
+	if err := r.Commit("nothing", "alice", zone); err != nil {
+		t.Fatal(err)
+	}
+	if _, again := headFiles(t, repo); again.Hash != commit.Hash {
+		t.Error("commit made without changes")
+	}
+
+	// A git command holding the index fails the commit
+	writeFile(t, dir, "zones/db.example.com", "zone changed\n")
+	lock := filepath.Join(dir, ".git", "index.lock")
+	writeFile(t, dir, ".git/index.lock", "")
+	if err := r.Commit("zone.update example.com", "alice", zone); err == nil {
+		t.Error("Commit() succeeded while index.lock was held")
+	}
+	if _, err := os.Stat(lock); err != nil {
+		t.Error("Commit() removed someone else's index.lock")
+	}
+	os.Remove(lock)
+
+	// A deletion
+	os.Remove(zone)
+	if err := r.Commit("zone.delete example.com", "bob", zone); err != nil {
+		t.Fatal(err)
+	}
+	files, commit = headFiles(t, repo)
+	if files["zones/db.example.com"] {
+		t.Error("deleted zone file still in the commit")
+	}
+	if commit.Author.Name != "bob" {
+		t.Errorf("deletion committed by %s, want bob", commit.Author.Name)
+	}
+	if files["zones/db.example.org"] {
+		t.Error("a file the commit was not given was committed")
+	}
+}
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"simple-coredns-manager/internal/auth"
+
+	"github.com/labstack/echo/v4"
+)
+
+type AccountPasswordData struct {
+	User      string
+	Available bool // false when a master password change has nowhere to be stored
+	MinLength int
+}
+
+// masterHash returns the current master password hash, which starts out as
+// the configured one and is replaced by AccountPasswordChange.
+func (h *Handler) masterHash() []byte {
+	h.passMu.RLock()
+	defer h.passMu.RUnlock()
+	return h.Config.MasterPasswordHash
+}
+
+// TokenGeneration returns the current token generation of user, which
+// session tokens must carry. A master hash made from a plaintext
+// MASTER_PASSWORD is salted anew on every start, so until the password is
+// first changed its generation is empty rather than derived from the hash.
+func (h *Handler) TokenGeneration(user string) (string, bool) {
+	if h.Users != nil {
+		return h.Users.Generation(user)
+	}
+	if user != auth.MasterUser {
+		return "", false
+	}
+	h.passMu.RLock()
+	defer h.passMu.RUnlock()
+	if h.Config.MasterPasswordSalted {
+		return "", true
+	}
+	return auth.PasswordGeneration(h.Config.MasterPasswordHash), true
+}
+
+// issueSession sets a fresh session cookie for user, valid for ttl.
+func (h *Handler) issueSession(c echo.Context, user string, ttl time.Duration) error {
+	gen, _ := h.TokenGeneration(user)
+	token, err := auth.GenerateToken(h.Keys.Primary(), user, gen, ttl)
+	if err != nil {
+		return err
+	}
+	auth.SetCookie(c.Response().Writer, h.Cookies, token, ttl)
+	return nil
+}
+
+// canChangePassword reports whether the password of user can be persisted.
+func (h *Handler) canChangePassword() bool {
+	return h.Users != nil || h.Config.PasswordFile != ""
+}
+
+func (h *Handler) AccountPasswordPage(c echo.Context) error {
+	data := AccountPasswordData{
+		User:      currentUser(c),
+		Available: h.canChangePassword(),
+		MinLength: auth.MinPasswordLength,
+	}
+	pd := h.page(c, "Change Password", "", data)
+	return c.Render(http.StatusOK, "account_password", pd)
+}
+
+// AccountPasswordChange verifies the current password and stores a bcrypt
+// hash of the new one, in the users file for per-user logins and in
+// PASSWORD_FILE for the master password.
+func (h *Handler) AccountPasswordChange(c echo.Context) error {
+	user := currentUser(c)
+	current := c.FormValue("current")
+	password := c.FormValue("password")
+
+	fail := func(msg string) error {
+		h.setFlash(c, "error", msg)
+		return c.Redirect(http.StatusSeeOther, "/account/password")
+	}
+
+	if !h.canChangePassword() {
+		return fail("Password changes need PASSWORD_FILE or STATE_DIR to be set")
+	}
+
+	var ok bool
+	if h.Users != nil {
+		ok = h.Users.Verify(user, current)
+	} else {
+		ok = auth.VerifyPassword(current, h.masterHash())
+	}
+	if !ok {
+		return fail("Current password is incorrect")
+	}
+	if password != c.FormValue("confirm") {
+		return fail("New passwords do not match")
+	}
+	if len(password) < auth.MinPasswordLength {
+		return fail(fmt.Sprintf("New password must be at least %d characters", auth.MinPasswordLength))
+	}
+	if password == current {
+		return fail("New password must differ from the current one")
+	}
+
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		return fail("Failed to hash password: " + err.Error())
+	}
+	if h.Users != nil {
+		err = h.Users.SetPassword(user, hash)
+	} else {
+		h.passMu.Lock()
+		if err = auth.SavePasswordHash(h.Config.PasswordFile, hash); err == nil {
+			h.Config.MasterPasswordHash = hash
+			h.Config.MasterPasswordSalted = false
+		}
+		h.passMu.Unlock()
+	}
+	if err != nil {
+		log.Printf("Password change for %s failed: %v", user, err)
+		return fail("Failed to save password: " + err.Error())
+	}
+
+	// The new password retires every session of this user, this one
+	// included; keep this one going under the new generation
+	ttl := h.Config.SessionTTL
+	if exp, ok := c.Get("session_exp").(time.Time); ok {
+		ttl = time.Until(exp)
+	}
+	if err := h.issueSession(c, user, ttl); err != nil {
+		log.Printf("Session renewal for %s failed: %v", user, err)
+	}
+
+	h.audit(c, "password.change", user, "")
+	h.setFlash(c, "success", "Password changed; other sessions have been signed out")
+	return c.Redirect(http.StatusSeeOther, "/account/password")
+}
@@ -0,0 +1,351 @@
+package handlers
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"strings"
+
+	"simple-coredns-manager/internal/coredns"
+
+	"github.com/labstack/echo/v4"
+)
+
+type WhoamiData struct {
+	User   string `json:"user"`
+	Role   string `json:"role"`
+	Method string `json:"auth_method"`
+}
+
+// APIWhoami reports who the request authenticated as, so provisioning
+// scripts can check their credentials without driving the login form.
+func (h *Handler) APIWhoami(c echo.Context) error {
+	method, _ := c.Get("auth_method").(string)
+	return c.JSON(http.StatusOK, WhoamiData{User: currentUser(c), Role: "admin", Method: method})
+}
+
+type InventoryData struct {
+	Zones        int            `json:"zones"`
+	TotalRecords int            `json:"total_records"`
+	RecordTypes  map[string]int `json:"record_types"`
+	Errors       []string       `json:"errors,omitempty"`
+}
+
+// APIInventory returns record type counts across all zones. Apex NS records
+// are not counted, matching the records shown in the zone editor.
+func (h *Handler) APIInventory(c echo.Context) error {
+	inv, err := h.inventory()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, inv)
+}
+
+func (h *Handler) inventory() (*InventoryData, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	domains, err := h.Zones.List()
+	if err != nil {
+		return nil, err
+	}
+
+	inv := &InventoryData{Zones: len(domains), RecordTypes: map[string]int{}}
+	for _, d := range domains {
+		zf, err := h.Zones.Read(d)
+		if err != nil {
+			inv.Errors = append(inv.Errors, d+": "+err.Error())
+			continue
+		}
+		for _, r := range zf.Records {
+			inv.RecordTypes[string(r.Type)]++
+			inv.TotalRecords++
+		}
+	}
+	return inv, nil
+}
+
+type RecordJSON struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	TTL      uint32 `json:"ttl"`
+	Value    string `json:"value"`
+	Priority uint16 `json:"priority,omitempty"`
+	Flag     uint8  `json:"flag,omitempty"`
+	Tag      string `json:"tag,omitempty"`
+}
+
+// APIRecords returns a zone's records filtered by the optional name, type,
+// and value query parameters. Filters match exactly (case-insensitively for
+// name and type), so clients can target one record set without pulling the
+// whole zone.
+func (h *Handler) APIRecords(c echo.Context) error {
+	domain := c.Param("domain")
+	if err := coredns.ValidateDomain(domain); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	name := c.QueryParam("name")
+	rtype := c.QueryParam("type")
+	value := c.QueryParam("value")
+
+	h.mu.RLock()
+	zf, err := h.Zones.Read(domain)
+	h.mu.RUnlock()
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "zone not found"})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	records := []RecordJSON{}
+	for _, r := range zf.Records {
+		if name != "" && !strings.EqualFold(r.Name, name) {
+			continue
+		}
+		if rtype != "" && !strings.EqualFold(string(r.Type), rtype) {
+			continue
+		}
+		if value != "" && r.Value != value {
+			continue
+		}
+		records = append(records, recordToJSON(r))
+	}
+	return c.JSON(http.StatusOK, records)
+}
+
+// APIResult is the response to a zone or record change made through the API.
+type APIResult struct {
+	Zone     string      `json:"zone"`
+	Record   *RecordJSON `json:"record,omitempty"`
+	Reloaded bool        `json:"reloaded"`
+	Warning  string      `json:"warning,omitempty"`
+}
+
+// APIRecordUpdate identifies a record by its current name, type, and value
+// and gives its replacement.
+type APIRecordUpdate struct {
+	Old    RecordJSON `json:"old"`
+	Record RecordJSON `json:"record"`
+}
+
+func apiError(c echo.Context, status int, msg string) error {
+	return c.JSON(status, map[string]string{"error": msg})
+}
+
+// apiZoneError maps a ZoneManager error to a status code.
+func apiZoneError(c echo.Context, err error) error {
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return apiError(c, http.StatusNotFound, "zone not found")
+	case errors.Is(err, coredns.ErrRecordNotFound):
+		return apiError(c, http.StatusNotFound, err.Error())
+	case errors.Is(err, coredns.ErrRecordExists):
+		return apiError(c, http.StatusConflict, err.Error())
+	default:
+		return apiError(c, http.StatusUnprocessableEntity, err.Error())
+	}
+}
+
+// apiFinish reloads CoreDNS after a change if the reload policy and the
+// reload query parameter ask for it, and writes the result.
+func (h *Handler) apiFinish(c echo.Context, status int, res APIResult) error {
+	if h.wantsReload(c) {
+		if err := h.reloadCoreDNS(c); err != nil {
+			res.Warning = "saved, but reload failed: " + err.Error()
+		} else {
+			res.Reloaded = true
+		}
+	}
+	return c.JSON(status, res)
+}
+
+func recordToJSON(r coredns.Record) RecordJSON {
+	return RecordJSON{
+		Name:     r.Name,
+		Type:     string(r.Type),
+		TTL:      r.TTL,
+		Value:    r.Value,
+		Priority: r.Priority,
+		Flag:     r.Flag,
+		Tag:      r.Tag,
+	}
+}
+
+// recordFromJSON is recordFromForm for API request bodies.
+func recordFromJSON(r RecordJSON) (coredns.Record, string) {
+	rec := coredns.Record{
+		Name:  strings.TrimSpace(r.Name),
+		Type:  coredns.RecordType(strings.ToUpper(strings.TrimSpace(r.Type))),
+		TTL:   r.TTL,
+		Value: strings.TrimSpace(r.Value),
+	}
+	if rec.Name == "" || rec.Type == "" || rec.Value == "" {
+		return coredns.Record{}, "name, type, and value are required"
+	}
+	switch rec.Type {
+	case coredns.TypeMX:
+		rec.Priority = r.Priority
+	case coredns.TypeCAA:
+		rec.Flag, rec.Tag = r.Flag, r.Tag
+		if err := coredns.ValidateCAA(rec.Flag, rec.Tag); err != nil {
+			return coredns.Record{}, err.Error()
+		}
+	}
+	return rec, ""
+}
+
+// APIZones lists the managed zones.
+func (h *Handler) APIZones(c echo.Context) error {
+	h.mu.RLock()
+	domains, err := h.Zones.List()
+	h.mu.RUnlock()
+	if err != nil {
+		return apiError(c, http.StatusInternalServerError, err.Error())
+	}
+	if domains == nil {
+		domains = []string{}
+	}
+	return c.JSON(http.StatusOK, domains)
+}
+
+// APIZoneCreate creates a zone from the default template. The body is
+// {"domain": "example.com"}.
+func (h *Handler) APIZoneCreate(c echo.Context) error {
+	var body struct {
+		Domain string `json:"domain"`
+	}
+	if err := c.Bind(&body); err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid JSON body")
+	}
+	domain := strings.TrimSpace(body.Domain)
+	if err := coredns.ValidateDomain(domain); err != nil {
+		return apiError(c, http.StatusBadRequest, err.Error())
+	}
+
+	h.mu.Lock()
+	if h.Zones.Exists(domain) {
+		h.mu.Unlock()
+		return apiError(c, http.StatusConflict, "zone already exists")
+	}
+	err := h.Zones.Create(domain)
+	h.mu.Unlock()
+	if err != nil {
+		return apiZoneError(c, err)
+	}
+	h.audit(c, "zone.create", domain, "")
+	return h.apiFinish(c, http.StatusCreated, APIResult{Zone: domain})
+}
+
+// APIZoneDelete deletes a zone file.
+func (h *Handler) APIZoneDelete(c echo.Context) error {
+	domain := c.Param("domain")
+	if err := coredns.ValidateDomain(domain); err != nil {
+		return apiError(c, http.StatusBadRequest, err.Error())
+	}
+
+	h.mu.Lock()
+	if !h.Zones.Exists(domain) {
+		h.mu.Unlock()
+		return apiError(c, http.StatusNotFound, "zone not found")
+	}
+	err := h.Zones.Delete(domain)
+	h.mu.Unlock()
+	if err != nil {
+		return apiZoneError(c, err)
+	}
+	h.audit(c, "zone.delete", domain, "")
+	return h.apiFinish(c, http.StatusOK, APIResult{Zone: domain})
+}
+
+// APIRecordAdd adds a record given as a RecordJSON body.
+func (h *Handler) APIRecordAdd(c echo.Context) error {
+	domain := c.Param("domain")
+	if err := coredns.ValidateDomain(domain); err != nil {
+		return apiError(c, http.StatusBadRequest, err.Error())
+	}
+	var body RecordJSON
+	if err := c.Bind(&body); err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid JSON body")
+	}
+	rec, msg := recordFromJSON(body)
+	if msg != "" {
+		return apiError(c, http.StatusBadRequest, msg)
+	}
+
+	h.mu.Lock()
+	err := h.Zones.AddRecord(domain, rec)
+	h.mu.Unlock()
+	if err != nil {
+		return apiZoneError(c, err)
+	}
+	h.audit(c, "record.add", domain, recordSummary(rec))
+	out := recordToJSON(rec)
+	return h.apiFinish(c, http.StatusCreated, APIResult{
+		Zone:    domain,
+		Record:  &out,
+		Warning: coredns.TargetWarning(rec.Type, rec.Value, domain),
+	})
+}
+
+// APIRecordUpdate replaces a record; see APIRecordUpdate for the body.
+func (h *Handler) APIRecordUpdate(c echo.Context) error {
+	domain := c.Param("domain")
+	if err := coredns.ValidateDomain(domain); err != nil {
+		return apiError(c, http.StatusBadRequest, err.Error())
+	}
+	var body APIRecordUpdate
+	if err := c.Bind(&body); err != nil {
+		return apiError(c, http.StatusBadRequest, "invalid JSON body")
+	}
+	old := coredns.Record{
+		Name:  strings.TrimSpace(body.Old.Name),
+		Type:  coredns.RecordType(strings.ToUpper(strings.TrimSpace(body.Old.Type))),
+		Value: strings.TrimSpace(body.Old.Value),
+	}
+	if old.Name == "" || old.Type == "" || old.Value == "" {
+		return apiError(c, http.StatusBadRequest, "old name, type, and value are required")
+	}
+	rec, msg := recordFromJSON(body.Record)
+	if msg != "" {
+		return apiError(c, http.StatusBadRequest, msg)
+	}
+
+	h.mu.Lock()
+	err := h.Zones.UpdateRecord(domain, old, rec)
+	h.mu.Unlock()
+	if err != nil {
+		return apiZoneError(c, err)
+	}
+	h.audit(c, "record.update", domain, recordSummary(old)+" -> "+recordSummary(rec))
+	out := recordToJSON(rec)
+	return h.apiFinish(c, http.StatusOK, APIResult{
+		Zone:    domain,
+		Record:  &out,
+		Warning: coredns.TargetWarning(rec.Type, rec.Value, domain),
+	})
+}
+
+// APIRecordDelete deletes the record given by the name, type, and value
+// query parameters.
+func (h *Handler) APIRecordDelete(c echo.Context) error {
+	domain := c.Param("domain")
+	if err := coredns.ValidateDomain(domain); err != nil {
+		return apiError(c, http.StatusBadRequest, err.Error())
+	}
+	name := strings.TrimSpace(c.QueryParam("name"))
+	rtype := strings.ToUpper(strings.TrimSpace(c.QueryParam("type")))
+	value := strings.TrimSpace(c.QueryParam("value"))
+	if name == "" || rtype == "" || value == "" {
+		return apiError(c, http.StatusBadRequest, "name, type, and value are required")
+	}
+
+	h.mu.Lock()
+	err := h.Zones.RemoveRecord(domain, name, coredns.RecordType(rtype), value)
+	h.mu.Unlock()
+	if err != nil {
+		return apiZoneError(c, err)
+	}
+	h.audit(c, "record.delete", domain, name+" "+rtype+" "+value)
+	return h.apiFinish(c, http.StatusOK, APIResult{Zone: domain})
+}
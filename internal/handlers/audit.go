@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"simple-coredns-manager/internal/audit"
+
+	"github.com/labstack/echo/v4"
+)
+
+// auditPageMax caps how many entries the audit page reads back.
+const auditPageMax = 1000
+
+type AuditData struct {
+	Enabled bool
+	Path    string
+	Limit   int
+	Entries []audit.Entry
+	Error   string
+}
+
+// AuditPage shows the newest audit log entries, 100 unless ?n= asks for
+// more.
+func (h *Handler) AuditPage(c echo.Context) error {
+	limit := 100
+	if n, err := strconv.Atoi(c.QueryParam("n")); err == nil && n > 0 {
+		limit = min(n, auditPageMax)
+	}
+
+	data := AuditData{Enabled: h.Audit.Enabled(), Path: h.Audit.Path(), Limit: limit}
+	entries, err := h.Audit.Tail(limit)
+	if err != nil {
+		data.Error = err.Error()
+	}
+	data.Entries = entries
+
+	pd := h.page(c, "Audit Log", "dashboard", data)
+	return c.Render(http.StatusOK, "audit", pd)
+}
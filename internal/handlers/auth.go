@@ -2,15 +2,22 @@ package handlers
 
 import (
 	"net/http"
+	"strings"
+	"time"
 
 	"simple-coredns-manager/internal/auth"
 
 	"github.com/labstack/echo/v4"
 )
 
+type LoginData struct {
+	MultiUser bool // a users file is configured, so the form asks for a user name
+	Username  string
+}
+
 func (h *Handler) LoginPage(c echo.Context) error {
 	// If already authenticated, redirect to dashboard
-	cookie, err := c.Cookie(auth.CookieName)
+	cookie, err := c.Cookie(h.Cookies.Name(auth.CookieName))
 	if err == nil && cookie.Value != "" {
 		return c.Redirect(http.StatusSeeOther, "/")
 	}
@@ -18,36 +25,84 @@ func (h *Handler) LoginPage(c echo.Context) error {
 	pd := PageData{
 		Title:     "Login",
 		CSRFToken: csrfToken(c),
+		Data:      LoginData{MultiUser: h.Users != nil},
 	}
 	return c.Render(http.StatusOK, "login", pd)
 }
 
 func (h *Handler) LoginSubmit(c echo.Context) error {
+	username := strings.TrimSpace(c.FormValue("username"))
 	password := c.FormValue("password")
-	if password == "" || !auth.VerifyPassword(password, h.Config.MasterPasswordHash) {
+	data := LoginData{MultiUser: h.Users != nil, Username: username}
+
+	var ok bool
+	if h.Users != nil {
+		ok = username != "" && password != "" && h.Users.Verify(username, password)
+	} else {
+		username = auth.MasterUser
+		ok = password != "" && auth.VerifyPassword(password, h.masterHash())
+	}
+	if !ok {
+		msg := "Invalid password"
+		if h.Users != nil {
+			msg = "Invalid user name or password"
+		}
 		pd := PageData{
 			Title:      "Login",
 			CSRFToken:  csrfToken(c),
-			FlashError: "Invalid password",
+			FlashError: msg,
+			Data:       data,
 		}
 		return c.Render(http.StatusUnauthorized, "login", pd)
 	}
 
-	token, err := auth.GenerateToken(h.Config.JWTSecret)
-	if err != nil {
+	ttl := h.Config.SessionTTL
+	if c.FormValue("remember") == "true" {
+		ttl = h.Config.RememberTTL
+	}
+	if err := h.issueSession(c, username, ttl); err != nil {
 		pd := PageData{
 			Title:      "Login",
 			CSRFToken:  csrfToken(c),
 			FlashError: "Failed to create session",
+			Data:       data,
 		}
 		return c.Render(http.StatusInternalServerError, "login", pd)
 	}
-
-	auth.SetCookie(c.Response().Writer, token)
 	return c.Redirect(http.StatusSeeOther, "/")
 }
 
 func (h *Handler) Logout(c echo.Context) error {
-	auth.ClearCookie(c.Response().Writer)
+	auth.ClearCookie(c.Response().Writer, h.Cookies)
 	return c.Redirect(http.StatusSeeOther, "/login")
 }
+
+func (h *Handler) JWTRotatePage(c echo.Context) error {
+	pd := h.page(c, "Rotate Session Secret", "dashboard", nil)
+	return c.Render(http.StatusOK, "admin_jwt", pd)
+}
+
+// JWTRotate promotes a new signing secret. Sessions signed with the previous
+// secret stay valid until it is rotated out or the manager restarts.
+func (h *Handler) JWTRotate(c echo.Context) error {
+	secret := c.FormValue("secret")
+	if secret != c.FormValue("confirm") {
+		h.setFlash(c, "error", "Secrets do not match")
+		return c.Redirect(http.StatusSeeOther, "/admin/jwt")
+	}
+	if err := h.Keys.Rotate([]byte(secret)); err != nil {
+		h.setFlash(c, "error", "Rotation failed: "+err.Error())
+		return c.Redirect(http.StatusSeeOther, "/admin/jwt")
+	}
+
+	// Re-issue the current session under the new primary, keeping its expiry
+	ttl := h.Config.SessionTTL
+	if exp, ok := c.Get("session_exp").(time.Time); ok {
+		ttl = time.Until(exp)
+	}
+	h.issueSession(c, currentUser(c), ttl)
+
+	h.audit(c, "jwt.rotate", "", "")
+	h.setFlash(c, "success", "Secret rotated. Set JWT_SECRET to the new secret and JWT_SECRET_SECONDARY to the old one before the next restart.")
+	return c.Redirect(http.StatusSeeOther, "/admin/jwt")
+}
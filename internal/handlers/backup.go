@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"simple-coredns-manager/internal/coredns"
+
+	"github.com/labstack/echo/v4"
+)
+
+// ConfigBackup streams the Corefile and every zone file as a .tar.gz archive.
+func (h *Handler) ConfigBackup(c echo.Context) error {
+	filename := fmt.Sprintf("coredns-config-%s.tar.gz", time.Now().Format("20060102-150405"))
+	return h.sendArchive(c, filename, "/state", func(w io.Writer) error {
+		return coredns.ExportArchive(w, h.Corefile, h.Zones)
+	})
+}
+
+// sendArchive writes an archive to a temporary file while holding off
+// writers, then sends it as a download once the lock is released, so a slow
+// client doesn't block saves. On failure it redirects to back with an error.
+func (h *Handler) sendArchive(c echo.Context, filename, back string, write func(io.Writer) error) error {
+	tmp, err := os.CreateTemp("", "coredns-manager-*.tar.gz")
+	if err != nil {
+		h.setFlash(c, "error", "Failed to create archive: "+err.Error())
+		return c.Redirect(http.StatusSeeOther, back)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	h.mu.RLock()
+	err = write(tmp)
+	h.mu.RUnlock()
+	if err == nil {
+		_, err = tmp.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		h.setFlash(c, "error", "Failed to create archive: "+err.Error())
+		return c.Redirect(http.StatusSeeOther, back)
+	}
+
+	c.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="`+filename+`"`)
+	return c.Stream(http.StatusOK, "application/gzip", tmp)
+}
+
+// ConfigRestore writes back the files of an archive made by ConfigBackup.
+// Every file is validated first and the restore is all-or-nothing.
+func (h *Handler) ConfigRestore(c echo.Context) error {
+	fh, err := c.FormFile("archive")
+	if err != nil {
+		h.setFlash(c, "error", "No archive uploaded")
+		return c.Redirect(http.StatusSeeOther, "/state")
+	}
+	f, err := fh.Open()
+	if err != nil {
+		h.setFlash(c, "error", "Failed to read upload: "+err.Error())
+		return c.Redirect(http.StatusSeeOther, "/state")
+	}
+	defer f.Close()
+
+	h.mu.Lock()
+	result, err := coredns.RestoreArchive(f, h.Corefile, h.Zones)
+	h.mu.Unlock()
+	if err != nil {
+		h.setFlash(c, "error", "Restore failed: "+err.Error())
+		return c.Redirect(http.StatusSeeOther, "/state")
+	}
+
+	msg := fmt.Sprintf("Restored %d zone(s)", len(result.Zones))
+	if result.Corefile {
+		msg += " and the Corefile"
+	}
+	h.audit(c, "config.restore", "", fmt.Sprintf("%s: %d zone(s), Corefile %t", fh.Filename, len(result.Zones), result.Corefile))
+
+	if h.wantsReload(c) {
+		if err := h.reloadCoreDNS(c); err != nil {
+			h.setFlash(c, "warning", msg+", but reload failed: "+err.Error())
+		} else {
+			h.setFlash(c, "success", msg+" and reloaded CoreDNS")
+		}
+	} else {
+		h.setFlash(c, "success", msg)
+	}
+	return c.Redirect(http.StatusSeeOther, "/state")
+}
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"net/http"
+	"net/url"
 
 	"simple-coredns-manager/internal/coredns"
 
@@ -9,69 +10,189 @@ import (
 )
 
 type CorefileData struct {
-	Content string
+	File      string // imported fragment being edited; empty for the main Corefile
+	Content   string
+	Lines     []coredns.CorefileLine
+	Fragments []string
+	Warnings  []string
+	Blocks    []coredns.ServerBlock // summary of the main Corefile
+	ParseErr  string
 }
 
 type CorefilePreviewData struct {
 	DiffContent string
+	Added       int
+	Removed     int
+	Warnings    []string
+}
+
+func warningStrings(ws []coredns.Warning) []string {
+	out := make([]string, 0, len(ws))
+	for _, w := range ws {
+		out = append(out, w.String())
+	}
+	return out
+}
+
+// readCorefile reads the main Corefile or, if file is set, an imported fragment.
+func (h *Handler) readCorefile(file string) (string, error) {
+	if file == "" {
+		return h.Corefile.Read()
+	}
+	return h.Corefile.ReadFragment(file)
+}
+
+func corefileURL(file string) string {
+	if file == "" {
+		return "/corefile"
+	}
+	return "/corefile?file=" + url.QueryEscape(file)
 }
 
 func (h *Handler) CorefileEdit(c echo.Context) error {
+	file := c.QueryParam("file")
+
 	h.mu.RLock()
-	content, err := h.Corefile.Read()
+	fragments, warnings := h.Corefile.Imports()
+	warnings = append(warnings, warningStrings(h.Corefile.CheckReferences(h.Config.ZoneDir))...)
+	content, err := h.readCorefile(file)
 	h.mu.RUnlock()
+
+	data := CorefileData{File: file, Fragments: fragments, Warnings: warnings}
 	if err != nil {
-		content = ""
-		pd := h.page(c, "Corefile", "corefile", CorefileData{Content: content})
+		pd := h.page(c, "Corefile", "corefile", data)
 		pd.FlashError = "Failed to read Corefile: " + err.Error()
 		return c.Render(http.StatusOK, "corefile", pd)
 	}
 
-	pd := h.page(c, "Corefile", "corefile", CorefileData{Content: content})
+	data.Content = content
+	h.mu.RLock()
+	data.Lines = h.Corefile.Annotate(content, h.Zones)
+	if file == "" {
+		if data.Blocks, err = h.Corefile.Parse(); err != nil {
+			data.ParseErr = err.Error()
+		}
+	}
+	h.mu.RUnlock()
+
+	pd := h.page(c, "Corefile", "corefile", data)
 	return c.Render(http.StatusOK, "corefile", pd)
 }
 
 func (h *Handler) CorefilePreview(c echo.Context) error {
+	file := c.FormValue("file")
 	newContent := c.FormValue("content")
 
 	h.mu.RLock()
-	original, err := h.Corefile.Read()
+	original, err := h.readCorefile(file)
+	warnings := h.Corefile.CheckReferencesWith(h.Config.ZoneDir, file, newContent)
 	h.mu.RUnlock()
 	if err != nil {
 		return c.HTML(http.StatusOK, `<div class="alert alert-danger">Failed to read current Corefile</div>`)
 	}
 
-	diff := coredns.GenerateDiff("Corefile", original, newContent)
-	data := CorefilePreviewData{DiffContent: diff}
+	name := "Corefile"
+	if file != "" {
+		name = file
+	}
+	added, removed, diff := coredns.GenerateDiffStats(name, original, newContent)
+	data := CorefilePreviewData{DiffContent: diff, Added: added, Removed: removed, Warnings: warningStrings(warnings)}
 	return c.Render(http.StatusOK, "corefile_preview", data)
 }
 
+// CorefileDiff returns just the diff between the posted content and the file
+// on disk, for the editor to show as the user types.
+func (h *Handler) CorefileDiff(c echo.Context) error {
+	file := c.FormValue("file")
+
+	h.mu.RLock()
+	original, err := h.readCorefile(file)
+	h.mu.RUnlock()
+	if err != nil {
+		return c.HTML(http.StatusOK, `<div class="alert alert-danger">Failed to read current Corefile</div>`)
+	}
+
+	name := "Corefile"
+	if file != "" {
+		name = file
+	}
+	return c.Render(http.StatusOK, "diff_fragment", newDiffData(name, original, c.FormValue("content")))
+}
+
 func (h *Handler) CorefileSave(c echo.Context) error {
+	file := c.FormValue("file")
 	content := c.FormValue("content")
-	reload := c.FormValue("reload") == "true"
+	reload := h.wantsReload(c)
+	redirect := corefileURL(file)
 
-	if err := h.Corefile.Validate(content); err != nil {
-		setFlash(c, "error", "Validation failed: "+err.Error())
-		return c.Redirect(http.StatusSeeOther, "/corefile")
+	validate := h.Corefile.Validate
+	if file != "" {
+		validate = h.Corefile.ValidateFragment
+	}
+	if err := validate(content); err != nil {
+		h.setFlash(c, "error", "Validation failed: "+err.Error())
+		return c.Redirect(http.StatusSeeOther, redirect)
 	}
 
 	h.mu.Lock()
-	err := h.Corefile.Write(content)
+	var err error
+	if file == "" {
+		err = h.Corefile.Write(content)
+	} else {
+		err = h.Corefile.WriteFragment(file, content)
+	}
 	h.mu.Unlock()
 	if err != nil {
-		setFlash(c, "error", "Failed to save Corefile: "+err.Error())
-		return c.Redirect(http.StatusSeeOther, "/corefile")
+		h.setFlash(c, "error", "Failed to save Corefile: "+err.Error())
+		return c.Redirect(http.StatusSeeOther, redirect)
+	}
+	target := file
+	if target == "" {
+		target = "Corefile"
 	}
+	h.audit(c, "corefile.save", target, "")
 
 	if reload {
-		if err := h.Docker.ReloadCoreDNS(); err != nil {
-			setFlash(c, "warning", "Corefile saved, but reload failed: "+err.Error())
+		if err := h.reloadCoreDNS(c); err != nil {
+			h.setFlash(c, "warning", "Corefile saved, but reload failed: "+err.Error())
 		} else {
-			setFlash(c, "success", "Corefile saved and CoreDNS reloaded")
+			h.setFlash(c, "success", "Corefile saved and CoreDNS reloaded")
 		}
 	} else {
-		setFlash(c, "success", "Corefile saved")
+		h.setFlash(c, "success", "Corefile saved")
+	}
+
+	return c.Redirect(http.StatusSeeOther, redirect)
+}
+
+type CorefileRunningData struct {
+	Path        string // Corefile path inside the container
+	DiffContent string // running → on disk; empty when they match
+	Error       string
+}
+
+// CorefileRunning compares the Corefile on disk with the one inside the
+// CoreDNS container, so edits that never reached the container stand out.
+func (h *Handler) CorefileRunning(c echo.Context) error {
+	data := CorefileRunningData{}
+
+	running, path, err := h.Docker.RunningCorefile()
+	if err != nil {
+		data.Error = err.Error()
+		return c.Render(http.StatusOK, "corefile_running", data)
 	}
+	data.Path = path
 
-	return c.Redirect(http.StatusSeeOther, "/corefile")
+	h.mu.RLock()
+	onDisk, err := h.Corefile.Read()
+	h.mu.RUnlock()
+	if err != nil {
+		data.Error = err.Error()
+		return c.Render(http.StatusOK, "corefile_running", data)
+	}
+
+	if running != onDisk {
+		data.DiffContent = coredns.GenerateDiff("Corefile", running, onDisk)
+	}
+	return c.Render(http.StatusOK, "corefile_running", data)
 }
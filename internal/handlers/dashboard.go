@@ -2,38 +2,44 @@ package handlers
 
 import (
 	"net/http"
+	"slices"
 
 	"github.com/labstack/echo/v4"
 )
 
 type DashboardData struct {
 	CoreDNSStatus  string
+	Health         string
 	ContainerID    string
 	DockerOK       bool
 	ZoneFileCount  int
 	ZoneFiles      []string
 	CorefileExists bool
+	Widgets        []string
+	Inventory      *InventoryData // only loaded when the types widget is shown
+	InventoryError string
 }
 
 func (h *Handler) Dashboard(c echo.Context) error {
-	dd := DashboardData{}
+	dd := DashboardData{Widgets: h.Config.DashboardWidgets}
 
-	// Check Docker/CoreDNS status
-	status, containerID, err := h.Docker.FindContainer()
-	if err != nil {
+	// Check Docker/CoreDNS status (cached; refreshed in the background)
+	st := h.Status.Get()
+	if !st.DockerOK {
 		dd.CoreDNSStatus = "Docker unavailable"
 		dd.DockerOK = false
-	} else if containerID == "" {
+	} else if st.ContainerID == "" {
 		dd.CoreDNSStatus = "Container not found"
 		dd.DockerOK = true
 	} else {
-		dd.CoreDNSStatus = status
-		dd.ContainerID = containerID[:12]
+		dd.CoreDNSStatus = st.State
+		dd.Health = st.Health
+		dd.ContainerID = st.ContainerID[:12]
 		dd.DockerOK = true
 	}
 
 	// Check Corefile
-	_, err = h.Corefile.Read()
+	_, err := h.Corefile.Read()
 	dd.CorefileExists = err == nil
 
 	// List zone files
@@ -45,6 +51,57 @@ func (h *Handler) Dashboard(c echo.Context) error {
 		dd.ZoneFileCount = len(zones)
 	}
 
+	if slices.Contains(dd.Widgets, "types") {
+		inv, err := h.inventory()
+		if err != nil {
+			dd.InventoryError = err.Error()
+			inv = &InventoryData{}
+		}
+		dd.Inventory = inv
+	}
+
 	pd := h.page(c, "Dashboard", "dashboard", dd)
 	return c.Render(http.StatusOK, "dashboard", pd)
 }
+
+// StatusJSON returns the cached CoreDNS container status for polling.
+func (h *Handler) StatusJSON(c echo.Context) error {
+	return c.JSON(http.StatusOK, h.Status.Get())
+}
+
+type CoreDNSInfoData struct {
+	Version  string
+	Compiled []string // plugins built into the binary, if known
+	Enabled  []PluginStatus
+	Error    string
+	Warning  string // the Corefile's plugins could not be read
+}
+
+type PluginStatus struct {
+	Name    string
+	Missing bool // used in the Corefile but not compiled into the binary
+}
+
+// CoreDNSInfo renders the running CoreDNS version and plugin list for the
+// dashboard. It is loaded lazily since asking the container takes an exec.
+func (h *Handler) CoreDNSInfo(c echo.Context) error {
+	data := CoreDNSInfoData{}
+
+	if info, err := h.Docker.CoreDNSInfo(); err != nil {
+		data.Error = err.Error()
+	} else {
+		data.Version = info.Version
+		data.Compiled = info.Plugins
+	}
+
+	enabled, err := h.Corefile.EnabledPlugins()
+	if err != nil {
+		data.Warning = err.Error()
+	}
+	for _, name := range enabled {
+		missing := len(data.Compiled) > 0 && !slices.Contains(data.Compiled, name)
+		data.Enabled = append(data.Enabled, PluginStatus{Name: name, Missing: missing})
+	}
+
+	return c.Render(http.StatusOK, "dashboard_coredns", data)
+}
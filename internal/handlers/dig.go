@@ -1,24 +1,52 @@
 package handlers
 
 import (
-	"context"
 	"fmt"
 	"net"
 	"net/http"
+	"net/netip"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/labstack/echo/v4"
+	"github.com/miekg/dns"
 )
 
 type DigData struct {
 	Query   string
 	Type    string
+	Server  string
+	Servers []DigServerResult
+	Notes   []string
+	Error   string
+	History []DigHistoryEntry
+}
+
+// DigServerResult is the answer from one of the queried servers.
+type DigServerResult struct {
 	Server  string
 	Results []DigResult
+	Notes   []string
 	Error   string
 }
 
+// digTypes are the record types the lookup page can query.
+var digTypes = map[string]uint16{
+	"A":     dns.TypeA,
+	"AAAA":  dns.TypeAAAA,
+	"CNAME": dns.TypeCNAME,
+	"MX":    dns.TypeMX,
+	"TXT":   dns.TypeTXT,
+	"NS":    dns.TypeNS,
+	"SOA":   dns.TypeSOA,
+	"SRV":   dns.TypeSRV,
+	"PTR":   dns.TypePTR,
+	"CAA":   dns.TypeCAA,
+}
+
 type DigResult struct {
 	Name  string
 	Type  string
@@ -28,123 +56,177 @@ type DigResult struct {
 
 func (h *Handler) DigPage(c echo.Context) error {
 	// Default DNS server is the CoreDNS container
-	server := h.Config.CoreDNSContainerName + ":53"
-	pd := h.page(c, "DNS Lookup", "dig", DigData{Server: server})
+	pd := h.page(c, "DNS Lookup", "dig", DigData{Server: h.Config.CoreDNSQueryAddr, History: h.Dig.Recent()})
 	return c.Render(http.StatusOK, "dig", pd)
 }
 
+// maxDigServers caps how many servers one lookup is sent to.
+const maxDigServers = 5
+
+// DigQuery looks a name up against one server, or against each server of a
+// comma-separated list so their answers can be compared side by side.
 func (h *Handler) DigQuery(c echo.Context) error {
 	query := strings.TrimSpace(c.FormValue("query"))
 	qtype := strings.TrimSpace(c.FormValue("type"))
-	server := strings.TrimSpace(c.FormValue("server"))
+	serverList := strings.TrimSpace(c.FormValue("server"))
 
 	if query == "" {
-		return c.HTML(http.StatusOK, `<div class="alert alert-warning">Enter a hostname to look up</div>`)
+		return c.HTML(http.StatusOK, `<div class="alert alert-warning">Enter a hostname or IP address to look up</div>`)
 	}
 	if qtype == "" {
 		qtype = "A"
 	}
-	if server == "" {
-		server = h.Config.CoreDNSContainerName + ":53"
+	if serverList == "" {
+		serverList = h.Config.CoreDNSQueryAddr
 	}
-	if !strings.Contains(server, ":") {
-		server = server + ":53"
+	var servers []string
+	for _, s := range strings.Split(serverList, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			servers = append(servers, digServerAddr(s))
+		}
 	}
 
-	resolver := &net.Resolver{
-		PreferGo: true,
-		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
-			d := net.Dialer{Timeout: 5 * time.Second}
-			return d.DialContext(ctx, "udp", server)
-		},
+	data := DigData{
+		Query:  query,
+		Type:   strings.ToUpper(qtype),
+		Server: strings.Join(servers, ", "),
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+	if len(servers) > maxDigServers {
+		data.Error = fmt.Sprintf("At most %d servers can be compared at once", maxDigServers)
+		return c.Render(http.StatusOK, "dig_result", data)
+	}
 
-	data := DigData{
-		Query:  query,
-		Type:   qtype,
-		Server: server,
-	}
-
-	switch strings.ToUpper(qtype) {
-	case "A", "AAAA":
-		ips, err := resolver.LookupHost(ctx, query)
-		if err != nil {
-			data.Error = err.Error()
-		} else {
-			for _, ip := range ips {
-				rtype := "A"
-				if strings.Contains(ip, ":") {
-					rtype = "AAAA"
-				}
-				if strings.ToUpper(qtype) != "A" && strings.ToUpper(qtype) != rtype {
-					continue
-				}
-				data.Results = append(data.Results, DigResult{
-					Name:  query,
-					Type:  rtype,
-					Value: ip,
-				})
-			}
-			if len(data.Results) == 0 {
-				data.Error = fmt.Sprintf("No %s records found", qtype)
-			}
+	rrtype, ok := digTypes[data.Type]
+	if !ok {
+		data.Error = "Unsupported record type: " + qtype
+		return c.Render(http.StatusOK, "dig_result", data)
+	}
+
+	// An IP address can only be looked up in reverse
+	qname := dns.Fqdn(query)
+	if _, err := netip.ParseAddr(query); err == nil {
+		qname, _ = dns.ReverseAddr(query)
+		if rrtype != dns.TypePTR {
+			data.Notes = append(data.Notes, query+" is an IP address, so this is a reverse (PTR) lookup")
 		}
-	case "CNAME":
-		cname, err := resolver.LookupCNAME(ctx, query)
-		if err != nil {
-			data.Error = err.Error()
-		} else {
-			data.Results = append(data.Results, DigResult{
-				Name:  query,
-				Type:  "CNAME",
-				Value: cname,
-			})
+		data.Type, rrtype = "PTR", dns.TypePTR
+	} else if rrtype == dns.TypePTR && !strings.HasSuffix(strings.ToLower(qname), ".arpa.") {
+		data.Error = fmt.Sprintf("%q is not a valid IPv4 or IPv6 address; PTR lookups need an address (or an in-addr.arpa / ip6.arpa name)", query)
+		return c.Render(http.StatusOK, "dig_result", data)
+	}
+
+	// Remember the lookup as typed so a history chip refills the form
+	h.Dig.Add(query, strings.ToUpper(qtype), serverList)
+	data.History = h.Dig.Recent()
+
+	opts := digOptions{TCP: c.FormValue("tcp") == "true"}
+	if v := strings.TrimSpace(c.FormValue("bufsize")); v != "" {
+		size, err := strconv.ParseUint(v, 10, 16)
+		if err != nil || size < 512 {
+			data.Error = "EDNS buffer size must be between 512 and 65535 bytes"
+			return c.Render(http.StatusOK, "dig_result", data)
 		}
-	case "MX":
-		mxs, err := resolver.LookupMX(ctx, query)
-		if err != nil {
-			data.Error = err.Error()
-		} else {
-			for _, mx := range mxs {
-				data.Results = append(data.Results, DigResult{
-					Name:  query,
-					Type:  "MX",
-					Value: fmt.Sprintf("%d %s", mx.Pref, mx.Host),
-				})
-			}
+		opts.UDPSize = uint16(size)
+	}
+
+	data.Servers = make([]DigServerResult, len(servers))
+	var wg sync.WaitGroup
+	for i, server := range servers {
+		wg.Add(1)
+		go func(i int, server string) {
+			defer wg.Done()
+			data.Servers[i] = digExchange(qname, rrtype, server, opts)
+		}(i, server)
+	}
+	wg.Wait()
+
+	if len(servers) > 1 && digAnswersDiffer(data.Servers) {
+		data.Notes = append(data.Notes, "The servers returned different answers")
+	}
+
+	return c.Render(http.StatusOK, "dig_result", data)
+}
+
+// digServerAddr adds the default port to a server given without one,
+// including bare IPv6 literals.
+func digServerAddr(server string) string {
+	if _, _, err := net.SplitHostPort(server); err == nil {
+		return server
+	}
+	return net.JoinHostPort(strings.Trim(server, "[]"), "53")
+}
+
+// digOptions are the transport settings for a lookup, like dig's +tcp
+// and +bufsize.
+type digOptions struct {
+	TCP     bool
+	UDPSize uint16 // EDNS0 UDP buffer size; 0 sends no EDNS0 record
+}
+
+// digExchange sends one query to server. A truncated UDP answer is retried
+// over TCP.
+func digExchange(qname string, rrtype uint16, server string, opts digOptions) DigServerResult {
+	out := DigServerResult{Server: server}
+
+	msg := new(dns.Msg)
+	msg.SetQuestion(qname, rrtype)
+	if opts.UDPSize > 0 {
+		msg.SetEdns0(opts.UDPSize, false)
+	}
+	client := &dns.Client{Timeout: 5 * time.Second}
+	if opts.TCP {
+		client.Net = "tcp"
+		out.Notes = append(out.Notes, "TCP")
+	}
+	resp, _, err := client.Exchange(msg, server)
+	if err == nil && resp.Truncated && !opts.TCP {
+		client.Net = "tcp"
+		resp, _, err = client.Exchange(msg, server)
+		out.Notes = append(out.Notes, "UDP answer was truncated, retried over TCP")
+	}
+
+	switch {
+	case err != nil:
+		out.Error = err.Error()
+	case resp.Rcode != dns.RcodeSuccess:
+		out.Error = fmt.Sprintf("%s: server answered %s", qname, dns.RcodeToString[resp.Rcode])
+	default:
+		for _, rr := range resp.Answer {
+			hdr := rr.Header()
+			out.Results = append(out.Results, DigResult{
+				Name:  hdr.Name,
+				Type:  dns.TypeToString[hdr.Rrtype],
+				Value: strings.TrimPrefix(rr.String(), hdr.String()),
+				TTL:   strconv.FormatUint(uint64(hdr.Ttl), 10),
+			})
 		}
-	case "TXT":
-		txts, err := resolver.LookupTXT(ctx, query)
-		if err != nil {
-			data.Error = err.Error()
-		} else {
-			for _, txt := range txts {
-				data.Results = append(data.Results, DigResult{
-					Name:  query,
-					Type:  "TXT",
-					Value: txt,
-				})
-			}
+		if len(out.Results) == 0 {
+			out.Error = fmt.Sprintf("No %s records found", dns.TypeToString[rrtype])
 		}
-	case "NS":
-		nss, err := resolver.LookupNS(ctx, query)
-		if err != nil {
-			data.Error = err.Error()
-		} else {
-			for _, ns := range nss {
-				data.Results = append(data.Results, DigResult{
-					Name:  query,
-					Type:  "NS",
-					Value: ns.Host,
-				})
-			}
+		if resp.Authoritative {
+			out.Notes = append(out.Notes, "Authoritative answer")
 		}
-	default:
-		data.Error = "Unsupported record type: " + qtype
 	}
+	return out
+}
 
-	return c.Render(http.StatusOK, "dig_result", data)
+// digAnswersDiffer reports whether the servers disagree on the answer
+// records (ignoring TTLs, which differ between caches) or on errors.
+func digAnswersDiffer(results []DigServerResult) bool {
+	key := func(r DigServerResult) string {
+		values := make([]string, 0, len(r.Results))
+		for _, res := range r.Results {
+			values = append(values, res.Type+" "+res.Value)
+		}
+		sort.Strings(values)
+		return r.Error + "|" + strings.Join(values, "|")
+	}
+	first := key(results[0])
+	for _, r := range results[1:] {
+		if key(r) != first {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"sync"
+	"time"
+)
+
+// digHistorySize is how many recent lookups the dig page remembers.
+const digHistorySize = 50
+
+// DigHistoryEntry is one remembered lookup.
+type DigHistoryEntry struct {
+	Query  string
+	Type   string
+	Server string
+	Time   time.Time
+}
+
+// DigHistory keeps the most recent dig lookups in memory, newest first.
+// It is shared by all sessions and cleared on restart.
+type DigHistory struct {
+	mu      sync.Mutex
+	entries []DigHistoryEntry
+}
+
+// Add records a lookup. Repeating an earlier lookup moves it to the front
+// instead of listing it twice.
+func (d *DigHistory) Add(query, qtype, server string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	entry := DigHistoryEntry{Query: query, Type: qtype, Server: server, Time: time.Now()}
+	kept := make([]DigHistoryEntry, 0, digHistorySize)
+	kept = append(kept, entry)
+	for _, e := range d.entries {
+		if len(kept) == digHistorySize {
+			break
+		}
+		if e.Query == query && e.Type == qtype && e.Server == server {
+			continue
+		}
+		kept = append(kept, e)
+	}
+	d.entries = kept
+}
+
+// Recent returns the remembered lookups, newest first.
+func (d *DigHistory) Recent() []DigHistoryEntry {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]DigHistoryEntry(nil), d.entries...)
+}
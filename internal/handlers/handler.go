@@ -1,12 +1,17 @@
 package handlers
 
 import (
-	"net/http"
+	"log"
+	"strings"
 	"sync"
 
+	"simple-coredns-manager/internal/audit"
+	"simple-coredns-manager/internal/auth"
 	"simple-coredns-manager/internal/config"
 	"simple-coredns-manager/internal/coredns"
 	"simple-coredns-manager/internal/docker"
+	"simple-coredns-manager/internal/git"
+	"simple-coredns-manager/internal/webhook"
 
 	"github.com/labstack/echo/v4"
 )
@@ -16,27 +21,145 @@ type Handler struct {
 	Corefile *coredns.CorefileManager
 	Zones    *coredns.ZoneManager
 	Docker   *docker.Client
+	Keys     *auth.Keyring
+	Users    *auth.Users // nil when logging in with the master password
+	Cookies  auth.Cookies
+	Status   *docker.StatusCache
+	Dig      *DigHistory
+	Audit    *audit.Logger
+	Git      *git.Repo         // nil unless GIT_REPO_DIR is set
+	Webhook  *webhook.Notifier // nil unless WEBHOOK_URL is set
 	mu       sync.RWMutex
+
+	passMu sync.RWMutex // guards Config.MasterPasswordHash
+
+	snapMu         sync.Mutex
+	lastReload     *coredns.Snapshot
+	reloadBaseline bool
 }
 
 type PageData struct {
 	Title         string
 	ActiveNav     string
 	Authenticated bool
+	User          string
 	CSRFToken     string
 	FlashSuccess  string
 	FlashError    string
 	FlashWarning  string
+	ReloadPolicy  string
+	ReadOnly      bool
 	Data          interface{}
 }
 
-func NewHandler(cfg *config.Config, cf *coredns.CorefileManager, zm *coredns.ZoneManager, dc *docker.Client) *Handler {
-	return &Handler{
+// DiffData renders a bare diff with its added/removed line counts.
+type DiffData struct {
+	DiffContent string
+	Added       int
+	Removed     int
+}
+
+func newDiffData(filename, original, modified string) DiffData {
+	added, removed, diff := coredns.GenerateDiffStats(filename, original, modified)
+	return DiffData{DiffContent: diff, Added: added, Removed: removed}
+}
+
+func NewHandler(cfg *config.Config, cf *coredns.CorefileManager, zm *coredns.ZoneManager, dc *docker.Client, keys *auth.Keyring, users *auth.Users, repo *git.Repo) *Handler {
+	h := &Handler{
 		Config:   cfg,
 		Corefile: cf,
 		Zones:    zm,
 		Docker:   dc,
+		Keys:     keys,
+		Users:    users,
+		Cookies:  auth.Cookies{Prefix: cfg.CookiePrefix, Domain: cfg.CookieDomain},
+		Status:   docker.NewStatusCache(dc, cfg.StatusCacheTTL),
+		Dig:      &DigHistory{},
+		Audit:    audit.New(cfg.AuditLog),
+		Git:      repo,
+		Webhook:  webhook.New(cfg.WebhookURL, cfg.WebhookSecret),
+	}
+	// Until the first reload, compare against the files as found at startup
+	h.lastReload = coredns.TakeSnapshot(h.managedFiles())
+	h.reloadBaseline = true
+	return h
+}
+
+// audit records a change made by the current user in the audit log, as a git
+// commit of the files it touched with GIT_REPO_DIR set, and notifies
+// WEBHOOK_URL. Failures are logged but don't fail the change, which has
+// already happened; a failed commit also shows a warning.
+func (h *Handler) audit(c echo.Context, action, target, detail string) {
+	user := currentUser(c)
+	err := h.Audit.Log(audit.Entry{
+		User:      user,
+		Action:    action,
+		Target:    target,
+		Detail:    detail,
+		RequestID: requestID(c),
+	})
+	if err != nil {
+		log.Printf("audit: %v", err)
+	}
+
+	if h.Git != nil {
+		subject := action
+		if target != "" {
+			subject += " " + target
+		}
+		if detail != "" {
+			subject += ": " + detail
+		}
+		message := subject
+		if id := requestID(c); id != "" {
+			message += "\n\nRequest-ID: " + id
+		}
+		// Holding off writers keeps another change to the same files out of
+		// this user's commit
+		h.mu.RLock()
+		err := h.Git.Commit(message, user, h.changedFiles(action, target)...)
+		h.mu.RUnlock()
+		if err != nil {
+			log.Printf("git: %s: %v", subject, err)
+			h.setFlash(c, "warning", "Saved, but committing to git failed: "+err.Error())
+		}
+	}
+
+	// Last, so a receiver that syncs from git finds the commit
+	h.Webhook.Send(webhook.Event{
+		Action:    action,
+		Domain:    target,
+		User:      user,
+		Detail:    detail,
+		RequestID: requestID(c),
+	})
+}
+
+// changedFiles returns the files an audited action wrote or removed, for
+// the git commit that records it.
+func (h *Handler) changedFiles(action, target string) []string {
+	switch {
+	case strings.HasPrefix(action, "zone.") || strings.HasPrefix(action, "record."):
+		return []string{h.Zones.Path(target)}
+	case action == "corefile.save":
+		return []string{h.Corefile.FilePath(target)}
+	case action == "config.restore":
+		files := []string{h.Corefile.Path()}
+		domains, _ := h.Zones.List()
+		for _, d := range domains {
+			files = append(files, h.Zones.Path(d))
+		}
+		return files
+	}
+	return nil
+}
+
+// currentUser returns the user the request authenticated as.
+func currentUser(c echo.Context) string {
+	if user, ok := c.Get("user").(string); ok {
+		return user
 	}
+	return auth.MasterUser
 }
 
 func csrfToken(c echo.Context) string {
@@ -51,47 +174,54 @@ func (h *Handler) page(c echo.Context, title, nav string, data interface{}) Page
 		Title:         title,
 		ActiveNav:     nav,
 		Authenticated: c.Get("authenticated") != nil,
+		User:          currentUser(c),
 		CSRFToken:     csrfToken(c),
+		ReloadPolicy:  h.Config.ReloadPolicy,
+		ReadOnly:      h.Config.ReadOnly,
 		Data:          data,
 	}
 
-	if sess := getFlash(c, "success"); sess != "" {
+	if sess := h.getFlash(c, "success"); sess != "" {
 		pd.FlashSuccess = sess
 	}
-	if sess := getFlash(c, "error"); sess != "" {
+	if sess := h.getFlash(c, "error"); sess != "" {
 		pd.FlashError = sess
 	}
-	if sess := getFlash(c, "warning"); sess != "" {
+	if sess := h.getFlash(c, "warning"); sess != "" {
 		pd.FlashWarning = sess
 	}
 
 	return pd
 }
 
-func setFlash(c echo.Context, kind, message string) {
-	c.SetCookie(&http.Cookie{
-		Name:     "flash_" + kind,
-		Value:    message,
-		Path:     "/",
-		HttpOnly: true,
-		SameSite: http.SameSiteStrictMode,
-		MaxAge:   10,
-	})
+// wantsReload applies the configured reload policy to a save request.
+func (h *Handler) wantsReload(c echo.Context) bool {
+	switch h.Config.ReloadPolicy {
+	case config.ReloadAlways:
+		return true
+	case config.ReloadManual:
+		return false
+	default:
+		return c.FormValue("reload") == "true"
+	}
+}
+
+func (h *Handler) setFlash(c echo.Context, kind, message string) {
+	// Error reports carry the request ID so they can be matched to the log
+	if kind == "error" {
+		if id := requestID(c); id != "" {
+			message += " (ref: " + id + ")"
+		}
+	}
+	c.SetCookie(h.Cookies.New("flash_"+kind, message, 10))
 }
 
-func getFlash(c echo.Context, kind string) string {
-	cookie, err := c.Cookie("flash_" + kind)
+func (h *Handler) getFlash(c echo.Context, kind string) string {
+	cookie, err := c.Cookie(h.Cookies.Name("flash_" + kind))
 	if err != nil || cookie.Value == "" {
 		return ""
 	}
 	// Clear the flash
-	c.SetCookie(&http.Cookie{
-		Name:     "flash_" + kind,
-		Value:    "",
-		Path:     "/",
-		HttpOnly: true,
-		SameSite: http.SameSiteStrictMode,
-		MaxAge:   -1,
-	})
+	c.SetCookie(h.Cookies.New("flash_"+kind, "", -1))
 	return cookie.Value
 }
@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+// BodyLimit rejects request bodies larger than limit with a 413 that names
+// the setting to raise, since legitimately large zones can hit the default.
+func BodyLimit(limit, setting string, skipper middleware.Skipper) echo.MiddlewareFunc {
+	if skipper == nil {
+		skipper = middleware.DefaultSkipper
+	}
+	limiter := middleware.BodyLimitWithConfig(middleware.BodyLimitConfig{
+		Skipper: skipper,
+		Limit:   limit,
+	})
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		limited := limiter(next)
+		return func(c echo.Context) error {
+			err := limited(c)
+			if errors.Is(err, echo.ErrStatusRequestEntityTooLarge) {
+				return echo.NewHTTPError(http.StatusRequestEntityTooLarge,
+					fmt.Sprintf("Request body exceeds the %s limit; raise %s to accept larger files", limit, setting))
+			}
+			return err
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// ReadOnly rejects every request that could change something with a 403, for
+// READ_ONLY deployments. Only GET, HEAD and OPTIONS requests and the listed
+// routes (POSTs that merely preview or query, matched by route pattern) get
+// through, so new mutating routes are blocked without being listed here.
+func ReadOnly(allowed ...string) echo.MiddlewareFunc {
+	safe := make(map[string]bool, len(allowed))
+	for _, path := range allowed {
+		safe[path] = true
+	}
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			switch c.Request().Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+				return next(c)
+			}
+			if safe[c.Path()] {
+				return next(c)
+			}
+			return echo.NewHTTPError(http.StatusForbidden,
+				"This manager is read-only; changes are disabled (READ_ONLY is set)")
+		}
+	}
+}
@@ -1,16 +1,126 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
+	"path/filepath"
+	"time"
+
+	"simple-coredns-manager/internal/coredns"
+	"simple-coredns-manager/internal/docker"
 
 	"github.com/labstack/echo/v4"
 )
 
+type ReloadData struct {
+	Since    time.Time
+	Baseline bool // no reload yet; comparing against startup state
+	Changes  []coredns.FileChange
+	DockerOK bool
+}
+
+// managedFiles maps display names to paths of every file CoreDNS loads
+// from this tool.
+func (h *Handler) managedFiles() map[string]string {
+	files := map[string]string{"Corefile": h.Corefile.Path()}
+	fragments, _ := h.Corefile.Imports()
+	for _, f := range fragments {
+		if filepath.IsAbs(f) {
+			files[f] = f
+		} else {
+			files[f] = filepath.Join(filepath.Dir(h.Corefile.Path()), f)
+		}
+	}
+	if zones, err := h.Zones.List(); err == nil {
+		for _, d := range zones {
+			files["db."+d] = h.Zones.Path(d)
+		}
+	}
+	return files
+}
+
+// reloadCoreDNS signals CoreDNS and, on success, records a snapshot of the
+// managed files so later changes can be shown before the next reload. With
+// VALIDATE_BEFORE_RELOAD, a config that fails `coredns -validate` is not
+// reloaded; when validation can't run at all, the reload goes ahead.
+func (h *Handler) reloadCoreDNS(c echo.Context) error {
+	// Without Docker the reload below reports the problem itself
+	if h.Config.ValidateBeforeReload && h.Docker.Available() {
+		err := h.Docker.ValidateConfig()
+		var vErr *docker.ValidationError
+		switch {
+		case errors.As(err, &vErr):
+			return fmt.Errorf("not reloading, config failed validation: %w", err)
+		case errors.Is(err, docker.ErrValidateUnsupported):
+			// Stock CoreDNS builds can't validate; reload as before
+		case err != nil:
+			log.Printf("reload: could not validate config, reloading anyway: %v", err)
+		}
+	}
+
+	err := h.Docker.ReloadCoreDNS()
+	h.Status.Invalidate()
+	if err != nil {
+		return err
+	}
+	h.audit(c, "reload", "", "")
+
+	h.mu.RLock()
+	snap := coredns.TakeSnapshot(h.managedFiles())
+	h.mu.RUnlock()
+
+	h.snapMu.Lock()
+	h.lastReload = snap
+	h.reloadBaseline = false
+	h.snapMu.Unlock()
+	return nil
+}
+
+func (h *Handler) reloadSnapshot() (*coredns.Snapshot, bool) {
+	h.snapMu.Lock()
+	defer h.snapMu.Unlock()
+	return h.lastReload, h.reloadBaseline
+}
+
+func (h *Handler) ReloadConfirm(c echo.Context) error {
+	snap, baseline := h.reloadSnapshot()
+
+	h.mu.RLock()
+	current := coredns.TakeSnapshot(h.managedFiles())
+	h.mu.RUnlock()
+
+	pd := h.page(c, "Reload CoreDNS", "dashboard", ReloadData{
+		Since:    snap.Taken,
+		Baseline: baseline,
+		Changes:  snap.Changes(current),
+		DockerOK: h.Docker.Available(),
+	})
+	return c.Render(http.StatusOK, "reload", pd)
+}
+
+// ReloadDiff shows how a managed file differs from the last-reload snapshot.
+func (h *Handler) ReloadDiff(c echo.Context) error {
+	name := c.QueryParam("file")
+	snap, _ := h.reloadSnapshot()
+
+	path, ok := h.managedFiles()[name]
+	current := ""
+	if ok {
+		h.mu.RLock()
+		current = coredns.TakeSnapshot(map[string]string{name: path}).Content(name)
+		h.mu.RUnlock()
+	}
+
+	return c.Render(http.StatusOK, "diff_fragment", newDiffData(name, snap.Content(name), current))
+}
+
 func (h *Handler) Reload(c echo.Context) error {
-	if err := h.Docker.ReloadCoreDNS(); err != nil {
-		setFlash(c, "error", "Reload failed: "+err.Error())
+	if err := h.reloadCoreDNS(c); err != nil {
+		h.setFlash(c, "error", "Reload failed: "+err.Error())
 	} else {
-		setFlash(c, "success", "CoreDNS reloaded successfully")
+		h.setFlash(c, "success", "CoreDNS reloaded successfully")
 	}
 	return c.Redirect(http.StatusSeeOther, "/")
 }
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// ResolveJSON answers a query from the zone files on disk.
+func (h *Handler) ResolveJSON(c echo.Context) error {
+	name := strings.TrimSpace(c.QueryParam("name"))
+	qtype := c.QueryParam("type")
+	if name == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "name is required"})
+	}
+	if qtype == "" {
+		qtype = "A"
+	}
+
+	h.mu.RLock()
+	res, err := h.Zones.Resolve(name, qtype)
+	h.mu.RUnlock()
+	if err != nil {
+		return c.JSON(http.StatusUnprocessableEntity, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+// ResolveFiles renders a file-based lookup in the dig results layout.
+func (h *Handler) ResolveFiles(c echo.Context) error {
+	query := strings.TrimSpace(c.FormValue("query"))
+	qtype := strings.TrimSpace(c.FormValue("type"))
+	if query == "" {
+		return c.HTML(http.StatusOK, `<div class="alert alert-warning">Enter a hostname to look up</div>`)
+	}
+	if qtype == "" {
+		qtype = "A"
+	}
+
+	data := DigData{Query: query, Type: qtype, Server: "zone files"}
+
+	h.mu.RLock()
+	res, err := h.Zones.Resolve(query, qtype)
+	h.mu.RUnlock()
+	if err != nil {
+		data.Error = err.Error()
+		return c.Render(http.StatusOK, "dig_result", data)
+	}
+
+	files := DigServerResult{Server: "zone files"}
+	for _, a := range res.Answers {
+		files.Results = append(files.Results, DigResult{
+			Name:  a.Name,
+			Type:  a.Type,
+			Value: a.Value,
+			TTL:   strconv.FormatUint(uint64(a.TTL), 10),
+		})
+	}
+	data.Servers = []DigServerResult{files}
+	data.Notes = res.Notes
+	return c.Render(http.StatusOK, "dig_result", data)
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"simple-coredns-manager/internal/coredns"
+
+	"github.com/labstack/echo/v4"
+)
+
+type ScratchpadData struct {
+	Origin   string
+	Records  []coredns.Record
+	SOA      *coredns.SOAData
+	Error    string
+	Warnings []string
+}
+
+func (h *Handler) ScratchpadPage(c echo.Context) error {
+	pd := h.page(c, "Scratchpad", "scratchpad", ScratchpadData{})
+	return c.Render(http.StatusOK, "scratchpad", pd)
+}
+
+// ScratchpadValidate parses pasted zone content against a user-supplied
+// origin and reports what it contains. Nothing is written.
+func (h *Handler) ScratchpadValidate(c echo.Context) error {
+	origin := strings.TrimSpace(c.FormValue("origin"))
+	content := c.FormValue("content")
+
+	data := ScratchpadData{Origin: origin}
+	if strings.TrimSpace(content) == "" {
+		data.Error = "Paste some zone content to validate"
+		return c.Render(http.StatusOK, "scratchpad_result", data)
+	}
+
+	records, soa, err := coredns.ParseZone(origin, content)
+	data.Records = records
+	data.SOA = soa
+	if err != nil {
+		data.Error = err.Error()
+	}
+	if soa == nil {
+		data.Warnings = append(data.Warnings, "No SOA record: fine for a snippet, but a complete zone file needs one")
+	}
+
+	return c.Render(http.StatusOK, "scratchpad_result", data)
+}
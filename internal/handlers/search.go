@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"simple-coredns-manager/internal/coredns"
+
+	"github.com/labstack/echo/v4"
+)
+
+type SearchData struct {
+	Query   string
+	Mode    string
+	Results []coredns.SearchResult
+	Errors  []string
+	Error   string
+	Done    bool
+}
+
+// SearchPage searches record names and values across all zones. The query is
+// a plain substring by default, or a glob / regular expression by mode.
+func (h *Handler) SearchPage(c echo.Context) error {
+	data := SearchData{
+		Query: strings.TrimSpace(c.QueryParam("q")),
+		Mode:  c.QueryParam("mode"),
+	}
+	if data.Mode == "" {
+		data.Mode = string(coredns.SearchSubstring)
+	}
+
+	if data.Query != "" {
+		match, err := coredns.NewMatcher(data.Query, coredns.SearchMode(data.Mode))
+		if err != nil {
+			data.Error = err.Error()
+		} else {
+			h.mu.RLock()
+			data.Results, data.Errors, err = h.Zones.Search(match)
+			h.mu.RUnlock()
+			if err != nil {
+				data.Error = err.Error()
+			}
+			data.Done = err == nil
+		}
+	}
+
+	pd := h.page(c, "Search", "search", data)
+	return c.Render(http.StatusOK, "search", pd)
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"simple-coredns-manager/internal/state"
+
+	"github.com/labstack/echo/v4"
+)
+
+type StateData struct {
+	StateDir string
+}
+
+func (h *Handler) StatePage(c echo.Context) error {
+	pd := h.page(c, "App State", "dashboard", StateData{StateDir: h.Config.StateDir})
+	return c.Render(http.StatusOK, "state", pd)
+}
+
+// stateSecrets are the files in the state directory that export and import
+// leave alone: the credentials stay with the host they were set on.
+func (h *Handler) stateSecrets() []string {
+	return []string{h.Config.PasswordFile, h.Config.UsersFile}
+}
+
+// StateExport streams the manager's state directory as a .tar.gz archive.
+func (h *Handler) StateExport(c echo.Context) error {
+	if h.Config.StateDir == "" {
+		h.setFlash(c, "error", "STATE_DIR is not configured")
+		return c.Redirect(http.StatusSeeOther, "/state")
+	}
+
+	filename := fmt.Sprintf("coredns-manager-state-%s.tar.gz", time.Now().Format("20060102-150405"))
+	return h.sendArchive(c, filename, "/state", func(w io.Writer) error {
+		return state.Export(h.Config.StateDir, w, h.stateSecrets()...)
+	})
+}
+
+// StateImport replaces the state directory contents with an uploaded archive.
+func (h *Handler) StateImport(c echo.Context) error {
+	if h.Config.StateDir == "" {
+		h.setFlash(c, "error", "STATE_DIR is not configured")
+		return c.Redirect(http.StatusSeeOther, "/state")
+	}
+
+	fh, err := c.FormFile("archive")
+	if err != nil {
+		h.setFlash(c, "error", "No archive uploaded")
+		return c.Redirect(http.StatusSeeOther, "/state")
+	}
+	f, err := fh.Open()
+	if err != nil {
+		h.setFlash(c, "error", "Failed to read upload: "+err.Error())
+		return c.Redirect(http.StatusSeeOther, "/state")
+	}
+	defer f.Close()
+
+	h.mu.Lock()
+	err = state.Import(h.Config.StateDir, f, h.stateSecrets()...)
+	h.mu.Unlock()
+	if err != nil {
+		h.setFlash(c, "error", "Import failed: "+err.Error())
+		return c.Redirect(http.StatusSeeOther, "/state")
+	}
+
+	h.audit(c, "state.import", "", fh.Filename)
+	h.setFlash(c, "success", "State imported; restart the manager to pick up all imported settings")
+	return c.Redirect(http.StatusSeeOther, "/state")
+}
@@ -1,13 +1,21 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"log"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"simple-coredns-manager/internal/coredns"
 
 	"github.com/labstack/echo/v4"
+	"github.com/miekg/dns"
 )
 
 type ZonesListData struct {
@@ -20,17 +28,63 @@ type ZonesListEntry struct {
 }
 
 type ZonesEditData struct {
-	Domain    string
-	Records   []coredns.Record
-	SOA       *coredns.SOAData
-	Raw       string
-	CSRFToken string
+	Domain     string
+	Records    []coredns.Record
+	SOA        *coredns.SOAData
+	Raw        string
+	Version    string
+	ModTime    int64 // UnixNano of the file when the page was rendered
+	CanUndo    bool
+	ReadOnly   bool
+	Sorted     bool // records by type and name instead of file order
+	CSRFToken  string
+	Visibility coredns.ZoneVisibility
+	Lint       []coredns.LintWarning
+}
+
+type ZonesNewTemplateData struct {
+	Domain  string
+	Content string
+}
+
+type ZonesUploadData struct {
+	Domain      string
+	Filename    string
+	Content     string
+	Version     string // of the zone the upload was diffed against
+	DiffContent string
+}
+
+type ZonesPreviewData struct {
+	DiffContent string
+	Added       int
+	Removed     int
+	Report      coredns.ValidationReport
+}
+
+type ZonesRenameData struct {
+	Domain      string
+	OldName     string
+	NewName     string
+	Plan        *coredns.RenamePlan
+	DiffContent string
+}
+
+type ZonesLiveSerialData struct {
+	FileSerial uint32
+	LiveSerial uint32
+	Server     string
+	Error      string
 }
 
 type ZonesRecordsData struct {
 	Domain    string
 	Records   []coredns.Record
 	CSRFToken string
+	Warning   string
+	Notice    string
+	Skipped   []coredns.ImportLineError
+	ReadOnly  bool
 }
 
 func (h *Handler) ZonesList(c echo.Context) error {
@@ -57,65 +111,326 @@ func (h *Handler) ZonesList(c echo.Context) error {
 	return c.Render(http.StatusOK, "zones_list", pd)
 }
 
+type ZonesNewData struct {
+	Domain    string
+	CIDR      string
+	CIDRError string
+}
+
+// ZonesNew shows the new zone form. With ?cidr= the domain is pre-filled
+// with the reverse zone for that network.
 func (h *Handler) ZonesNew(c echo.Context) error {
-	pd := h.page(c, "New DNS Zone", "zones", nil)
+	data := ZonesNewData{CIDR: strings.TrimSpace(c.QueryParam("cidr"))}
+	if data.CIDR != "" {
+		name, err := coredns.ReverseZoneName(data.CIDR)
+		if err != nil {
+			data.CIDRError = err.Error()
+		} else {
+			data.Domain = name
+		}
+	}
+	pd := h.page(c, "New DNS Zone", "zones", data)
 	return c.Render(http.StatusOK, "zones_new", pd)
 }
 
+func (h *Handler) ZonesNewTemplate(c echo.Context) error {
+	domain := strings.TrimSpace(c.FormValue("domain"))
+
+	h.mu.RLock()
+	content, err := h.Zones.PreviewCreate(domain)
+	h.mu.RUnlock()
+	if err != nil {
+		return c.HTML(http.StatusOK, `<div class="alert alert-danger">`+template.HTMLEscapeString(err.Error())+`</div>`)
+	}
+
+	return c.Render(http.StatusOK, "zones_new_template", ZonesNewTemplateData{
+		Domain:  domain,
+		Content: content,
+	})
+}
+
 func (h *Handler) ZonesEdit(c echo.Context) error {
 	domain := c.Param("domain")
 	if err := coredns.ValidateDomain(domain); err != nil {
-		setFlash(c, "error", "Invalid domain: "+err.Error())
+		h.setFlash(c, "error", "Invalid domain: "+err.Error())
 		return c.Redirect(http.StatusSeeOther, "/zones")
 	}
 
+	// Stat before reading, so a change in between makes the page look
+	// stale rather than hiding the change
 	h.mu.RLock()
+	modTime, _, _ := h.Zones.Stat(domain)
 	zf, err := h.Zones.Read(domain)
 	h.mu.RUnlock()
 	if err != nil {
-		setFlash(c, "error", "Failed to read: "+err.Error())
+		h.setFlash(c, "error", "Failed to read: "+err.Error())
 		return c.Redirect(http.StatusSeeOther, "/zones")
 	}
 
+	visibility := coredns.VisibilityFor(domain, h.Config.PublicZones, h.Config.InternalZones)
+	// Lint before sorting so its warnings follow the file
+	lint := coredns.LintAddresses(zf.Records, visibility)
+	sorted := sortedView(c)
 	pd := h.page(c, domain+" — DNS Zone", "zones", ZonesEditData{
-		Domain:    domain,
-		Records:   zf.Records,
-		SOA:       zf.SOA,
-		Raw:       zf.Raw,
-		CSRFToken: csrfToken(c),
+		Domain:     domain,
+		Records:    viewRecords(zf.Records, sorted),
+		SOA:        zf.SOA,
+		Raw:        zf.Raw,
+		Version:    zf.Version,
+		ModTime:    modTime.UnixNano(),
+		CanUndo:    h.Zones.CanUndo(domain),
+		ReadOnly:   h.Config.ReadOnly,
+		Sorted:     sorted,
+		CSRFToken:  csrfToken(c),
+		Visibility: visibility,
+		Lint:       lint,
 	})
 	return c.Render(http.StatusOK, "zones_edit", pd)
 }
 
+// sortedView reports whether the records table was asked for in sorted
+// order; the edit page passes ?order=sorted on to its record requests.
+func sortedView(c echo.Context) bool {
+	return c.FormValue("order") == "sorted"
+}
+
+// viewRecords returns records in the order the table shows them.
+func viewRecords(records []coredns.Record, sorted bool) []coredns.Record {
+	if sorted {
+		coredns.SortRecords(records)
+	}
+	return records
+}
+
+// ZonesLiveSerial compares the SOA serial on disk with the one CoreDNS is
+// serving, to catch saves that were never reloaded.
+func (h *Handler) ZonesLiveSerial(c echo.Context) error {
+	domain := c.Param("domain")
+	if err := coredns.ValidateDomain(domain); err != nil {
+		return c.HTML(http.StatusBadRequest, `<div class="alert alert-danger">Invalid domain</div>`)
+	}
+
+	h.mu.RLock()
+	zf, err := h.Zones.Read(domain)
+	h.mu.RUnlock()
+	if err != nil || zf.SOA == nil {
+		return c.HTML(http.StatusOK, "")
+	}
+
+	data := ZonesLiveSerialData{
+		FileSerial: zf.SOA.Serial,
+		Server:     h.Config.CoreDNSQueryAddr,
+	}
+	live, err := coredns.QuerySOASerial(data.Server, domain)
+	if err != nil {
+		data.Error = err.Error()
+	} else {
+		data.LiveSerial = live
+	}
+	return c.Render(http.StatusOK, "zones_live_serial", data)
+}
+
+type ZonesChangedData struct {
+	Changed  bool      `json:"changed"`
+	Exists   bool      `json:"exists"`
+	Modified time.Time `json:"modified,omitzero"`
+	ModTime  int64     `json:"mod_time,omitempty"` // Modified as Unix nanoseconds, for the next ?since=
+}
+
+// ZonesChanged reports whether a zone file was modified after ?since= (Unix
+// nanoseconds), so an open edit page can warn before it overwrites someone
+// else's change.
+func (h *Handler) ZonesChanged(c echo.Context) error {
+	domain := c.Param("domain")
+	if err := coredns.ValidateDomain(domain); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	since, err := strconv.ParseInt(c.QueryParam("since"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "since must be a Unix time in nanoseconds"})
+	}
+
+	h.mu.RLock()
+	modTime, _, err := h.Zones.Stat(domain)
+	h.mu.RUnlock()
+	if errors.Is(err, fs.ErrNotExist) {
+		return c.JSON(http.StatusOK, ZonesChangedData{Changed: true})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, ZonesChangedData{
+		Changed:  modTime.UnixNano() != since,
+		Exists:   true,
+		Modified: modTime,
+		ModTime:  modTime.UnixNano(),
+	})
+}
+
 func (h *Handler) ZonesAddRecord(c echo.Context) error {
 	domain := c.Param("domain")
+	if err := coredns.ValidateDomain(domain); err != nil {
+		return c.HTML(http.StatusBadRequest, `<div class="alert alert-danger">Invalid domain</div>`)
+	}
+
+	rec, msg := recordFromForm(c)
+	if msg != "" {
+		return c.HTML(http.StatusBadRequest, `<div class="alert alert-danger">`+template.HTMLEscapeString(msg)+`</div>`)
+	}
+
+	syncPTR := c.FormValue("sync_ptr") == "true" && (rec.Type == coredns.TypeA || rec.Type == coredns.TypeAAAA)
+
+	h.mu.Lock()
+	err := h.Zones.AddRecord(domain, rec)
+	warning := coredns.TargetWarning(rec.Type, rec.Value, domain)
+	if err == nil && syncPTR {
+		warning = h.addMatchingPTR(domain, rec)
+	}
+	h.mu.Unlock()
+	// A repeated submit is not an error; the record is there either way
+	if errors.Is(err, coredns.ErrRecordExists) {
+		return h.renderRecordsTableWarning(c, domain, "This "+string(rec.Type)+" record for "+rec.Name+" already exists; nothing was added.")
+	}
+	if err != nil {
+		return c.HTML(http.StatusUnprocessableEntity, `<div class="alert alert-danger">Failed to add record: `+template.HTMLEscapeString(err.Error())+`</div>`)
+	}
+
+	h.audit(c, "record.add", domain, recordSummary(rec))
+	return h.renderRecordsTableWarning(c, domain, warning)
+}
+
+// addMatchingPTR adds the PTR record for a new A or AAAA record to the
+// managed reverse zone covering its address. It returns a warning if the
+// PTR could not be added; the forward record is kept either way. The
+// caller holds h.mu.
+func (h *Handler) addMatchingPTR(domain string, rec coredns.Record) string {
+	zone, name, ok := h.Zones.ReverseZoneFor(rec.Value)
+	if !ok {
+		return "No reverse zone for " + rec.Value + " is managed here, so no PTR record was added."
+	}
+
+	target := dns.Fqdn(domain)
+	switch {
+	case rec.Name == "@":
+	case strings.HasSuffix(rec.Name, "."):
+		target = rec.Name
+	default:
+		target = rec.Name + "." + target
+	}
+	ptr := coredns.Record{Name: name, Type: coredns.TypePTR, TTL: rec.TTL, Value: target}
+	if err := h.Zones.AddRecord(zone, ptr); err != nil && !errors.Is(err, coredns.ErrRecordExists) {
+		return "Record added, but the PTR record in " + zone + " was not: " + err.Error()
+	}
+	return ""
+}
+
+// ZonesImport adds the records in a pasted block of zone lines and reports
+// the lines it skipped.
+func (h *Handler) ZonesImport(c echo.Context) error {
+	domain := c.Param("domain")
+	if err := coredns.ValidateDomain(domain); err != nil {
+		return c.HTML(http.StatusBadRequest, `<div class="alert alert-danger">Invalid domain</div>`)
+	}
+	block := c.FormValue("records")
+	if strings.TrimSpace(block) == "" {
+		return c.HTML(http.StatusBadRequest, `<div class="alert alert-danger">Paste at least one record line</div>`)
+	}
+
+	h.mu.Lock()
+	added, err := h.Zones.ImportRecords(domain, block)
+	h.mu.Unlock()
+
+	var skipped *coredns.ImportError
+	if err != nil && !errors.As(err, &skipped) {
+		return c.HTML(http.StatusUnprocessableEntity, `<div class="alert alert-danger">Import failed: `+template.HTMLEscapeString(err.Error())+`</div>`)
+	}
+	if added > 0 {
+		h.audit(c, "record.import", domain, fmt.Sprintf("%d record(s)", added))
+	}
+
+	h.mu.RLock()
+	zf, err := h.Zones.Read(domain)
+	h.mu.RUnlock()
+	data := ZonesRecordsData{
+		Domain:    domain,
+		CSRFToken: csrfToken(c),
+		Notice:    fmt.Sprintf("Imported %d record(s)", added),
+		ReadOnly:  h.Config.ReadOnly,
+	}
+	if err == nil {
+		data.Records = viewRecords(zf.Records, sortedView(c))
+	}
+	if skipped != nil {
+		data.Skipped = skipped.Lines
+	}
+	return c.Render(http.StatusOK, "zones_records", data)
+}
+
+// ZonesUpdateRecord changes a record in place. The record to replace is
+// identified by old_name, old_type, and old_value; the new values use the
+// same fields as the add form.
+func (h *Handler) ZonesUpdateRecord(c echo.Context) error {
+	domain := c.Param("domain")
+	if err := coredns.ValidateDomain(domain); err != nil {
+		return c.HTML(http.StatusBadRequest, `<div class="alert alert-danger">Invalid domain</div>`)
+	}
+
+	old := coredns.Record{
+		Name:  strings.TrimSpace(c.FormValue("old_name")),
+		Type:  coredns.RecordType(strings.TrimSpace(c.FormValue("old_type"))),
+		Value: strings.TrimSpace(c.FormValue("old_value")),
+	}
+	if old.Name == "" || old.Type == "" || old.Value == "" {
+		return c.HTML(http.StatusBadRequest, `<div class="alert alert-danger">The record to update is not identified</div>`)
+	}
+	rec, msg := recordFromForm(c)
+	if msg != "" {
+		return c.HTML(http.StatusBadRequest, `<div class="alert alert-danger">`+template.HTMLEscapeString(msg)+`</div>`)
+	}
+
+	h.mu.Lock()
+	err := h.Zones.UpdateRecord(domain, old, rec)
+	h.mu.Unlock()
+	if err != nil {
+		return c.HTML(http.StatusUnprocessableEntity, `<div class="alert alert-danger">Failed to update record: `+template.HTMLEscapeString(err.Error())+`</div>`)
+	}
+	h.audit(c, "record.update", domain, recordSummary(old)+" -> "+recordSummary(rec))
+
+	return h.renderRecordsTableWarning(c, domain, coredns.TargetWarning(rec.Type, rec.Value, domain))
+}
+
+// recordSummary describes a record for the audit log.
+func recordSummary(r coredns.Record) string {
+	return r.Name + " " + string(r.Type) + " " + r.Value
+}
+
+// recordFromForm reads a record from the add/edit form fields. It returns a
+// user-facing message if a field is missing or malformed.
+func recordFromForm(c echo.Context) (coredns.Record, string) {
 	name := strings.TrimSpace(c.FormValue("name"))
 	rtype := strings.TrimSpace(c.FormValue("type"))
 	value := strings.TrimSpace(c.FormValue("value"))
 	ttlStr := strings.TrimSpace(c.FormValue("ttl"))
 	priorityStr := strings.TrimSpace(c.FormValue("priority"))
 
-	if err := coredns.ValidateDomain(domain); err != nil {
-		return c.HTML(http.StatusBadRequest, `<div class="alert alert-danger">Invalid domain</div>`)
-	}
 	if name == "" || rtype == "" || value == "" {
-		return c.HTML(http.StatusBadRequest, `<div class="alert alert-danger">Name, type, and value are required</div>`)
+		return coredns.Record{}, "Name, type, and value are required"
 	}
 
 	var ttl uint32
 	if ttlStr != "" {
-		t, err := strconv.ParseUint(ttlStr, 10, 32)
+		t, err := coredns.ParseTTL(ttlStr)
 		if err != nil {
-			return c.HTML(http.StatusBadRequest, `<div class="alert alert-danger">Invalid TTL</div>`)
+			return coredns.Record{}, err.Error()
 		}
-		ttl = uint32(t)
+		ttl = t
 	}
 
 	var priority uint16
 	if priorityStr != "" && coredns.RecordType(rtype) == coredns.TypeMX {
 		p, err := strconv.ParseUint(priorityStr, 10, 16)
 		if err != nil {
-			return c.HTML(http.StatusBadRequest, `<div class="alert alert-danger">Invalid priority</div>`)
+			return coredns.Record{}, "Invalid MX priority (allowed: 0-65535)"
 		}
 		priority = uint16(p)
 	}
@@ -128,13 +443,86 @@ func (h *Handler) ZonesAddRecord(c echo.Context) error {
 		Priority: priority,
 	}
 
+	if rec.Type == coredns.TypeCAA {
+		f, err := strconv.ParseUint(c.FormValue("flag"), 10, 8)
+		if err != nil {
+			return coredns.Record{}, "Invalid CAA flag"
+		}
+		rec.Flag = uint8(f)
+		rec.Tag = c.FormValue("tag")
+		if err := coredns.ValidateCAA(rec.Flag, rec.Tag); err != nil {
+			return coredns.Record{}, err.Error()
+		}
+	}
+	return rec, ""
+}
+
+// ZonesAddEmailRecord builds a validated SPF, DMARC, or DKIM value and stores
+// it as a plain TXT record.
+func (h *Handler) ZonesAddEmailRecord(c echo.Context) error {
+	domain := c.Param("domain")
+	kind := c.FormValue("kind")
+	name := strings.TrimSpace(c.FormValue("name"))
+
+	if err := coredns.ValidateDomain(domain); err != nil {
+		return c.HTML(http.StatusBadRequest, `<div class="alert alert-danger">Invalid domain</div>`)
+	}
+
+	var value string
+	var err error
+	switch kind {
+	case "spf":
+		if name == "" {
+			name = "@"
+		}
+		value, err = coredns.BuildSPF(strings.Fields(c.FormValue("mechanisms")), c.FormValue("all"))
+	case "dmarc":
+		// DMARC policies always live under _dmarc
+		if name == "" || name == "@" {
+			name = "_dmarc"
+		} else if !strings.HasPrefix(name, "_dmarc") {
+			name = "_dmarc." + name
+		}
+		value, err = coredns.BuildDMARC(coredns.DMARCPolicy{
+			Policy:          c.FormValue("policy"),
+			SubdomainPolicy: c.FormValue("sp"),
+			Percent:         strings.TrimSpace(c.FormValue("pct")),
+			RUA:             strings.TrimSpace(c.FormValue("rua")),
+			RUF:             strings.TrimSpace(c.FormValue("ruf")),
+			ADKIM:           c.FormValue("adkim"),
+			ASPF:            c.FormValue("aspf"),
+		})
+	case "dkim":
+		selector := strings.TrimSpace(c.FormValue("selector"))
+		if selector == "" {
+			return c.HTML(http.StatusBadRequest, `<div class="alert alert-danger">DKIM selector is required</div>`)
+		}
+		name = selector + "._domainkey"
+		value, err = coredns.BuildDKIM(c.FormValue("key_type"), c.FormValue("public_key"))
+	default:
+		return c.HTML(http.StatusBadRequest, `<div class="alert alert-danger">Unknown email record kind</div>`)
+	}
+	if err != nil {
+		return c.HTML(http.StatusBadRequest, `<div class="alert alert-danger">`+template.HTMLEscapeString(err.Error())+`</div>`)
+	}
+
+	rec := coredns.Record{
+		Name:  name,
+		Type:  coredns.TypeTXT,
+		Value: value,
+	}
+
 	h.mu.Lock()
-	err := h.Zones.AddRecord(domain, rec)
+	err = h.Zones.AddRecord(domain, rec)
 	h.mu.Unlock()
+	if errors.Is(err, coredns.ErrRecordExists) {
+		return c.HTML(http.StatusConflict, `<div class="alert alert-warning">This TXT record for `+template.HTMLEscapeString(name)+` already exists; nothing was added.</div>`)
+	}
 	if err != nil {
-		return c.HTML(http.StatusInternalServerError, `<div class="alert alert-danger">Failed to add record: `+err.Error()+`</div>`)
+		return c.HTML(http.StatusBadRequest, `<div class="alert alert-danger">Failed to add record: `+template.HTMLEscapeString(err.Error())+`</div>`)
 	}
 
+	h.audit(c, "record.add", domain, recordSummary(rec))
 	return h.renderRecordsTable(c, domain)
 }
 
@@ -154,11 +542,17 @@ func (h *Handler) ZonesRemoveRecord(c echo.Context) error {
 	if err != nil {
 		return c.HTML(http.StatusInternalServerError, `<div class="alert alert-danger">Failed to delete record: `+err.Error()+`</div>`)
 	}
+	h.audit(c, "record.delete", domain, name+" "+rtype+" "+value)
 
 	return h.renderRecordsTable(c, domain)
 }
 
 func (h *Handler) renderRecordsTable(c echo.Context, domain string) error {
+	return h.renderRecordsTableWarning(c, domain, "")
+}
+
+// renderRecordsTableWarning renders the records table with a warning above it.
+func (h *Handler) renderRecordsTableWarning(c echo.Context, domain, warning string) error {
 	h.mu.RLock()
 	zf, err := h.Zones.Read(domain)
 	h.mu.RUnlock()
@@ -170,8 +564,10 @@ func (h *Handler) renderRecordsTable(c echo.Context, domain string) error {
 
 	data := ZonesRecordsData{
 		Domain:    domain,
-		Records:   records,
+		Records:   viewRecords(records, sortedView(c)),
 		CSRFToken: csrfToken(c),
+		Warning:   warning,
+		ReadOnly:  h.Config.ReadOnly,
 	}
 	return c.Render(http.StatusOK, "zones_records", data)
 }
@@ -191,14 +587,39 @@ func (h *Handler) ZonesPreview(c echo.Context) error {
 		original = ""
 	}
 
-	diff := coredns.GenerateDiff("db."+domain, original, newContent)
-	return c.Render(http.StatusOK, "zones_preview", struct{ DiffContent string }{diff})
+	// The save path still runs plain Validate; the report only informs
+	report, _ := h.Zones.ValidateDetailed(domain, newContent)
+	added, removed, diff := coredns.GenerateDiffStats("db."+domain, original, newContent)
+	return c.Render(http.StatusOK, "zones_preview", ZonesPreviewData{
+		DiffContent: diff,
+		Added:       added,
+		Removed:     removed,
+		Report:      report,
+	})
+}
+
+// ZonesDiff returns just the diff between the posted content and the zone
+// file on disk, for the raw editor to show as the user types.
+func (h *Handler) ZonesDiff(c echo.Context) error {
+	domain := c.Param("domain")
+	if err := coredns.ValidateDomain(domain); err != nil {
+		return c.HTML(http.StatusOK, `<div class="alert alert-danger">Invalid domain</div>`)
+	}
+
+	h.mu.RLock()
+	original, err := h.Zones.ReadRaw(domain)
+	h.mu.RUnlock()
+	if err != nil {
+		original = ""
+	}
+
+	return c.Render(http.StatusOK, "diff_fragment", newDiffData("db."+domain, original, c.FormValue("content")))
 }
 
 func (h *Handler) ZonesSave(c echo.Context) error {
 	domain := c.Param("domain")
 	content := c.FormValue("content")
-	reload := c.FormValue("reload") == "true"
+	reload := h.wantsReload(c)
 
 	isNew := domain == "new"
 	if isNew {
@@ -206,53 +627,421 @@ func (h *Handler) ZonesSave(c echo.Context) error {
 	}
 
 	if err := coredns.ValidateDomain(domain); err != nil {
-		setFlash(c, "error", "Invalid domain: "+err.Error())
+		h.setFlash(c, "error", "Invalid domain: "+err.Error())
 		return c.Redirect(http.StatusSeeOther, "/zones")
 	}
 
 	h.mu.Lock()
 	var err error
+	if isNew && h.Zones.Exists(domain) {
+		h.mu.Unlock()
+		h.setFlash(c, "error", "Zone already exists: "+domain)
+		return c.Redirect(http.StatusSeeOther, "/zones/"+domain)
+	}
 	if isNew && content == "" {
 		// Creating a new zone with default template
 		err = h.Zones.Create(domain)
 	} else {
 		if content == "" {
 			h.mu.Unlock()
-			setFlash(c, "error", "Content cannot be empty")
+			h.setFlash(c, "error", "Content cannot be empty")
 			return c.Redirect(http.StatusSeeOther, "/zones/"+domain)
 		}
 		// Validate before saving
 		if vErr := h.Zones.Validate(domain, content); vErr != nil {
 			h.mu.Unlock()
-			setFlash(c, "error", "Validation failed: "+vErr.Error())
+			h.setFlash(c, "error", "Validation failed: "+vErr.Error())
 			return c.Redirect(http.StatusSeeOther, "/zones/"+domain)
 		}
-		err = h.Zones.Write(domain, content)
+		err = h.Zones.Write(domain, content, c.FormValue("version"))
 	}
 	h.mu.Unlock()
 
+	if errors.Is(err, coredns.ErrVersionConflict) {
+		h.setFlash(c, "error", "Not saved: the zone file changed since you loaded it. Review the current content and reapply your edit.")
+		return c.Redirect(http.StatusSeeOther, "/zones/"+domain)
+	}
 	if err != nil {
-		setFlash(c, "error", "Failed to save: "+err.Error())
+		h.setFlash(c, "error", "Failed to save: "+err.Error())
 		return c.Redirect(http.StatusSeeOther, "/zones/"+domain)
 	}
+	if isNew {
+		h.audit(c, "zone.create", domain, "")
+	} else {
+		h.audit(c, "zone.save", domain, "")
+	}
 
 	if reload {
-		if err := h.Docker.ReloadCoreDNS(); err != nil {
-			setFlash(c, "warning", "Saved, but reload failed: "+err.Error())
+		if err := h.reloadCoreDNS(c); err != nil {
+			h.setFlash(c, "warning", "Saved, but reload failed: "+err.Error())
+		} else {
+			h.setFlash(c, "success", "Saved and CoreDNS reloaded")
+		}
+	} else {
+		h.setFlash(c, "success", "Saved successfully")
+	}
+
+	return c.Redirect(http.StatusSeeOther, "/zones/"+domain)
+}
+
+// ZonesUndo reverts the last change made to a zone through the manager.
+func (h *Handler) ZonesUndo(c echo.Context) error {
+	domain := c.Param("domain")
+	if err := coredns.ValidateDomain(domain); err != nil {
+		h.setFlash(c, "error", "Invalid domain: "+err.Error())
+		return c.Redirect(http.StatusSeeOther, "/zones")
+	}
+
+	h.mu.Lock()
+	err := h.Zones.Undo(domain)
+	h.mu.Unlock()
+	if err != nil {
+		h.setFlash(c, "error", "Undo failed: "+err.Error())
+		return c.Redirect(http.StatusSeeOther, "/zones/"+domain)
+	}
+	h.audit(c, "zone.undo", domain, "")
+
+	if h.wantsReload(c) {
+		if err := h.reloadCoreDNS(c); err != nil {
+			h.setFlash(c, "warning", "Last change undone, but reload failed: "+err.Error())
 		} else {
-			setFlash(c, "success", "Saved and CoreDNS reloaded")
+			h.setFlash(c, "success", "Last change undone and CoreDNS reloaded")
 		}
 	} else {
-		setFlash(c, "success", "Saved successfully")
+		h.setFlash(c, "success", "Last change undone")
+	}
+	return c.Redirect(http.StatusSeeOther, "/zones/"+domain)
+}
+
+// ZonesSOA updates the SOA timers and hostnames of a zone.
+func (h *Handler) ZonesSOA(c echo.Context) error {
+	domain := c.Param("domain")
+	if err := coredns.ValidateDomain(domain); err != nil {
+		h.setFlash(c, "error", "Invalid domain: "+err.Error())
+		return c.Redirect(http.StatusSeeOther, "/zones")
+	}
+
+	soa := coredns.SOAData{
+		MName: strings.TrimSpace(c.FormValue("mname")),
+		RName: strings.TrimSpace(c.FormValue("rname")),
+	}
+	for _, f := range []struct {
+		name, label string
+		dst         *uint32
+	}{
+		{"refresh", "Refresh", &soa.Refresh},
+		{"retry", "Retry", &soa.Retry},
+		{"expire", "Expire", &soa.Expire},
+		{"minttl", "Minimum TTL", &soa.MinTTL},
+	} {
+		v, err := strconv.ParseUint(strings.TrimSpace(c.FormValue(f.name)), 10, 32)
+		if err != nil {
+			h.setFlash(c, "error", f.label+" must be a whole number of seconds")
+			return c.Redirect(http.StatusSeeOther, "/zones/"+domain)
+		}
+		*f.dst = uint32(v)
+	}
+
+	h.mu.Lock()
+	err := h.Zones.WriteSOA(domain, soa)
+	h.mu.Unlock()
+	if err != nil {
+		h.setFlash(c, "error", "Failed to update SOA: "+err.Error())
+		return c.Redirect(http.StatusSeeOther, "/zones/"+domain)
+	}
+	h.audit(c, "zone.soa", domain, "")
+
+	if h.wantsReload(c) {
+		if err := h.reloadCoreDNS(c); err != nil {
+			h.setFlash(c, "warning", "SOA updated, but reload failed: "+err.Error())
+		} else {
+			h.setFlash(c, "success", "SOA updated and CoreDNS reloaded")
+		}
+	} else {
+		h.setFlash(c, "success", "SOA updated")
+	}
+	return c.Redirect(http.StatusSeeOther, "/zones/"+domain)
+}
+
+// ZonesDS returns the DS and DNSKEY records to hand to the parent zone.
+func (h *Handler) ZonesDS(c echo.Context) error {
+	domain := c.Param("domain")
+	if err := coredns.ValidateDomain(domain); err != nil {
+		return c.String(http.StatusBadRequest, "Invalid domain: "+err.Error())
+	}
+
+	h.mu.RLock()
+	ds, keys, err := h.Zones.DelegationSigner(domain, c.QueryParam("sha1") == "true")
+	h.mu.RUnlock()
+	if err != nil {
+		return c.String(http.StatusNotFound, err.Error())
+	}
+
+	var b strings.Builder
+	b.WriteString("; DS records for the parent zone\n")
+	for _, d := range ds {
+		b.WriteString(d.String() + "\n")
+	}
+	b.WriteString("\n; Registrar form values (key tag, algorithm, digest type, digest)\n")
+	for _, d := range ds {
+		fmt.Fprintf(&b, "%d %d %d %s\n", d.KeyTag, d.Algorithm, d.DigestType, strings.ToUpper(d.Digest))
+	}
+	b.WriteString("\n; Key-signing DNSKEY records\n")
+	for _, k := range keys {
+		b.WriteString(k.String() + "\n")
+	}
+	return c.String(http.StatusOK, b.String())
+}
+
+// ZonesExport downloads a zone file, either as stored or, with
+// format=normalized, re-emitted in canonical one-record-per-line form.
+func (h *Handler) ZonesExport(c echo.Context) error {
+	domain := c.Param("domain")
+	if err := coredns.ValidateDomain(domain); err != nil {
+		return c.String(http.StatusBadRequest, "Invalid domain: "+err.Error())
+	}
+	if !h.Zones.Exists(domain) {
+		return c.String(http.StatusNotFound, "Zone not found")
+	}
+
+	if c.QueryParam("format") != "normalized" {
+		// Set explicitly: the ".com"/".net" suffix would otherwise drive
+		// content type detection
+		c.Response().Header().Set(echo.HeaderContentType, echo.MIMETextPlainCharsetUTF8)
+		return c.Attachment(h.Zones.Path(domain), "db."+domain)
+	}
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, echo.MIMETextPlainCharsetUTF8)
+	res.Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", "db."+domain+".normalized"))
+	if err := h.Zones.ExportNormalized(domain, res); err != nil {
+		if !res.Committed {
+			res.Header().Del(echo.HeaderContentDisposition)
+			return c.String(http.StatusUnprocessableEntity, err.Error())
+		}
+		log.Printf("Export of %s aborted: %v", domain, err)
+	}
+	return nil
+}
+
+// ZonesExportAll downloads every zone concatenated into one text document
+// for review, each under a "; === db.<domain> ===" separator with its
+// $ORIGIN. With format=normalized each zone is in canonical form.
+func (h *Handler) ZonesExportAll(c echo.Context) error {
+	normalized := c.QueryParam("format") == "normalized"
+
+	h.mu.RLock()
+	domains, err := h.Zones.List()
+	h.mu.RUnlock()
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, echo.MIMETextPlainCharsetUTF8)
+	res.Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", "zones-"+time.Now().Format("20060102-150405")+".txt"))
+	res.WriteHeader(http.StatusOK)
+
+	fmt.Fprintf(res, "; All zones managed by simple-coredns-manager, exported %s\n", time.Now().UTC().Format(time.RFC3339))
+	for _, domain := range domains {
+		fmt.Fprintf(res, "\n; === db.%s ===\n$ORIGIN %s.\n", domain, domain)
+		if normalized {
+			err = h.Zones.ExportNormalized(domain, res)
+		} else {
+			var raw string
+			if raw, err = h.Zones.ReadRaw(domain); err == nil {
+				_, err = io.WriteString(res, strings.TrimRight(raw, "\n")+"\n")
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(res, "; ERROR: %s could not be exported: %v\n", domain, err)
+		}
+	}
+	return nil
+}
+
+// ZonesUpload validates an uploaded zone file and shows a diff against the
+// current file. Nothing is written until ZonesUploadConfirm.
+func (h *Handler) ZonesUpload(c echo.Context) error {
+	domain := c.Param("domain")
+	if err := coredns.ValidateDomain(domain); err != nil {
+		h.setFlash(c, "error", "Invalid domain: "+err.Error())
+		return c.Redirect(http.StatusSeeOther, "/zones")
 	}
 
+	fh, err := c.FormFile("file")
+	if err != nil {
+		h.setFlash(c, "error", "No file uploaded")
+		return c.Redirect(http.StatusSeeOther, "/zones/"+domain)
+	}
+	f, err := fh.Open()
+	if err != nil {
+		h.setFlash(c, "error", "Failed to read upload: "+err.Error())
+		return c.Redirect(http.StatusSeeOther, "/zones/"+domain)
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		h.setFlash(c, "error", "Failed to read upload: "+err.Error())
+		return c.Redirect(http.StatusSeeOther, "/zones/"+domain)
+	}
+	content := strings.ReplaceAll(string(data), "\r\n", "\n")
+
+	if err := h.Zones.Validate(domain, content); err != nil {
+		h.setFlash(c, "error", "Upload rejected: "+err.Error())
+		return c.Redirect(http.StatusSeeOther, "/zones/"+domain)
+	}
+
+	h.mu.RLock()
+	original, err := h.Zones.ReadRaw(domain)
+	h.mu.RUnlock()
+	if err != nil {
+		h.setFlash(c, "error", "Failed to read: "+err.Error())
+		return c.Redirect(http.StatusSeeOther, "/zones")
+	}
+
+	pd := h.page(c, domain+" — Upload", "zones", ZonesUploadData{
+		Domain:      domain,
+		Filename:    fh.Filename,
+		Content:     content,
+		Version:     coredns.ContentVersion(original),
+		DiffContent: coredns.GenerateDiff("db."+domain, original, content),
+	})
+	return c.Render(http.StatusOK, "zones_upload", pd)
+}
+
+// ZonesUploadConfirm replaces the zone with the previewed upload, keeping a
+// backup of the previous version even when backups are otherwise disabled.
+func (h *Handler) ZonesUploadConfirm(c echo.Context) error {
+	domain := c.Param("domain")
+	content := c.FormValue("content")
+	if err := coredns.ValidateDomain(domain); err != nil {
+		h.setFlash(c, "error", "Invalid domain: "+err.Error())
+		return c.Redirect(http.StatusSeeOther, "/zones")
+	}
+
+	if err := h.Zones.Validate(domain, content); err != nil {
+		h.setFlash(c, "error", "Upload rejected: "+err.Error())
+		return c.Redirect(http.StatusSeeOther, "/zones/"+domain)
+	}
+
+	h.mu.Lock()
+	var err error
+	if !h.Zones.BackupsEnabled() {
+		// The write only backs up when backups are enabled
+		if _, err = h.Zones.Backup(domain); err != nil {
+			err = fmt.Errorf("failed to back up the current zone: %w", err)
+		}
+	}
+	if err == nil {
+		err = h.Zones.Write(domain, content, c.FormValue("version"))
+	}
+	h.mu.Unlock()
+	if errors.Is(err, coredns.ErrVersionConflict) {
+		h.setFlash(c, "error", "Not replaced: the zone file changed since the upload was previewed. Upload it again to review the new diff.")
+		return c.Redirect(http.StatusSeeOther, "/zones/"+domain)
+	}
+	if err != nil {
+		h.setFlash(c, "error", "Failed to replace zone: "+err.Error())
+		return c.Redirect(http.StatusSeeOther, "/zones/"+domain)
+	}
+
+	h.audit(c, "zone.upload", domain, "")
+	h.setFlash(c, "success", "Zone replaced from upload (previous version backed up)")
 	return c.Redirect(http.StatusSeeOther, "/zones/"+domain)
 }
 
+// ZonesRename previews renaming a record owner across the zone.
+func (h *Handler) ZonesRename(c echo.Context) error {
+	domain := c.Param("domain")
+	if err := coredns.ValidateDomain(domain); err != nil {
+		h.setFlash(c, "error", "Invalid domain: "+err.Error())
+		return c.Redirect(http.StatusSeeOther, "/zones")
+	}
+	oldName := strings.TrimSpace(c.FormValue("old_name"))
+	newName := strings.TrimSpace(c.FormValue("new_name"))
+
+	h.mu.RLock()
+	plan, err := h.Zones.PreviewRename(domain, oldName, newName)
+	h.mu.RUnlock()
+	if err != nil {
+		h.setFlash(c, "error", "Rename rejected: "+err.Error())
+		return c.Redirect(http.StatusSeeOther, "/zones/"+domain)
+	}
+
+	pd := h.page(c, domain+" — Rename", "zones", ZonesRenameData{
+		Domain:      domain,
+		OldName:     oldName,
+		NewName:     newName,
+		Plan:        plan,
+		DiffContent: coredns.GenerateDiff("db."+domain, plan.Original, plan.Content),
+	})
+	return c.Render(http.StatusOK, "zones_rename", pd)
+}
+
+// ZonesRenameConfirm applies a previewed rename. The rewrite is recomputed
+// against the current file so edits made since the preview aren't lost.
+func (h *Handler) ZonesRenameConfirm(c echo.Context) error {
+	domain := c.Param("domain")
+	if err := coredns.ValidateDomain(domain); err != nil {
+		h.setFlash(c, "error", "Invalid domain: "+err.Error())
+		return c.Redirect(http.StatusSeeOther, "/zones")
+	}
+	oldName := strings.TrimSpace(c.FormValue("old_name"))
+	newName := strings.TrimSpace(c.FormValue("new_name"))
+
+	h.mu.Lock()
+	plan, err := h.Zones.RenameOwner(domain, oldName, newName)
+	h.mu.Unlock()
+	if err != nil {
+		h.setFlash(c, "error", "Rename failed: "+err.Error())
+		return c.Redirect(http.StatusSeeOther, "/zones/"+domain)
+	}
+
+	h.audit(c, "record.rename", domain, oldName+" -> "+newName)
+	msg := fmt.Sprintf("Renamed %s to %s (%d record(s), %d CNAME target(s))", oldName, newName, plan.Records, plan.Targets)
+	if h.wantsReload(c) {
+		if err := h.reloadCoreDNS(c); err != nil {
+			h.setFlash(c, "warning", msg+", but reload failed: "+err.Error())
+		} else {
+			h.setFlash(c, "success", msg+" and CoreDNS reloaded")
+		}
+	} else {
+		h.setFlash(c, "success", msg)
+	}
+	return c.Redirect(http.StatusSeeOther, "/zones/"+domain)
+}
+
+// ZonesClone creates a new zone from a copy of this one.
+func (h *Handler) ZonesClone(c echo.Context) error {
+	domain := c.Param("domain")
+	if err := coredns.ValidateDomain(domain); err != nil {
+		h.setFlash(c, "error", "Invalid domain: "+err.Error())
+		return c.Redirect(http.StatusSeeOther, "/zones")
+	}
+	target := strings.ToLower(strings.TrimSuffix(strings.TrimSpace(c.FormValue("new_domain")), "."))
+	if err := coredns.ValidateDomain(target); err != nil {
+		h.setFlash(c, "error", "Invalid new domain: "+err.Error())
+		return c.Redirect(http.StatusSeeOther, "/zones/"+domain)
+	}
+
+	h.mu.Lock()
+	err := h.Zones.Clone(domain, target)
+	h.mu.Unlock()
+	if err != nil {
+		h.setFlash(c, "error", "Clone failed: "+err.Error())
+		return c.Redirect(http.StatusSeeOther, "/zones/"+domain)
+	}
+
+	h.audit(c, "zone.clone", target, "from "+domain)
+	h.setFlash(c, "success", "'"+target+"' created from '"+domain+"'. Add a Corefile server block for it to serve the zone.")
+	return c.Redirect(http.StatusSeeOther, "/zones/"+target)
+}
+
 func (h *Handler) ZonesDelete(c echo.Context) error {
 	domain := c.Param("domain")
 	if err := coredns.ValidateDomain(domain); err != nil {
-		setFlash(c, "error", "Invalid domain: "+err.Error())
+		h.setFlash(c, "error", "Invalid domain: "+err.Error())
 		return c.Redirect(http.StatusSeeOther, "/zones")
 	}
 
@@ -260,10 +1049,15 @@ func (h *Handler) ZonesDelete(c echo.Context) error {
 	err := h.Zones.Delete(domain)
 	h.mu.Unlock()
 	if err != nil {
-		setFlash(c, "error", "Failed to delete: "+err.Error())
+		h.setFlash(c, "error", "Failed to delete: "+err.Error())
 		return c.Redirect(http.StatusSeeOther, "/zones")
 	}
 
-	setFlash(c, "success", "'"+domain+"' deleted")
+	h.audit(c, "zone.delete", domain, "")
+	msg := "'" + domain + "' deleted"
+	if h.Zones.BackupsEnabled() {
+		msg += "; it can be restored from /zones/" + domain + "/backups"
+	}
+	h.setFlash(c, "success", msg)
 	return c.Redirect(http.StatusSeeOther, "/zones")
 }
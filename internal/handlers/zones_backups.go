@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+
+	"simple-coredns-manager/internal/coredns"
+
+	"github.com/labstack/echo/v4"
+)
+
+type ZonesBackupsData struct {
+	Domain      string
+	Enabled     bool
+	Dir         string
+	Backups     []coredns.Backup
+	Selected    string // ID of the backup being compared, if any
+	DiffContent string // current zone -> selected backup
+	Error       string
+}
+
+// ZonesBackups lists the saved previous versions of a zone. With ?id= it
+// also shows what restoring that backup would change.
+func (h *Handler) ZonesBackups(c echo.Context) error {
+	domain := c.Param("domain")
+	if err := coredns.ValidateDomain(domain); err != nil {
+		h.setFlash(c, "error", "Invalid domain: "+err.Error())
+		return c.Redirect(http.StatusSeeOther, "/zones")
+	}
+
+	data := ZonesBackupsData{
+		Domain:  domain,
+		Enabled: h.Zones.BackupsEnabled(),
+		Dir:     h.Config.BackupDir,
+	}
+
+	h.mu.RLock()
+	backups, err := h.Zones.ListBackups(domain)
+	current, _ := h.Zones.ReadRaw(domain)
+	var backup string
+	if err == nil && c.QueryParam("id") != "" {
+		data.Selected = c.QueryParam("id")
+		backup, err = h.Zones.ReadBackup(domain, data.Selected)
+	}
+	h.mu.RUnlock()
+
+	data.Backups = backups
+	if err != nil {
+		data.Error = err.Error()
+		data.Selected = ""
+	} else if data.Selected != "" {
+		data.DiffContent = coredns.GenerateDiff("db."+domain, current, backup)
+	}
+
+	pd := h.page(c, domain+" — Backups", "zones", data)
+	return c.Render(http.StatusOK, "zones_backups", pd)
+}
+
+// ZonesBackupRestore writes a backup back as the zone's content. The version
+// being replaced is itself backed up, so a restore can be undone the same way.
+func (h *Handler) ZonesBackupRestore(c echo.Context) error {
+	domain := c.Param("domain")
+	id := c.FormValue("id")
+	if err := coredns.ValidateDomain(domain); err != nil {
+		h.setFlash(c, "error", "Invalid domain: "+err.Error())
+		return c.Redirect(http.StatusSeeOther, "/zones")
+	}
+	backupsURL := "/zones/" + domain + "/backups?id=" + url.QueryEscape(id)
+
+	h.mu.Lock()
+	err := h.Zones.RestoreBackup(domain, id)
+	h.mu.Unlock()
+	if err != nil {
+		h.setFlash(c, "error", "Restore failed: "+err.Error())
+		return c.Redirect(http.StatusSeeOther, backupsURL)
+	}
+
+	h.audit(c, "zone.restore", domain, "backup "+id)
+	if h.wantsReload(c) {
+		if err := h.reloadCoreDNS(c); err != nil {
+			h.setFlash(c, "warning", "Backup restored, but reload failed: "+err.Error())
+		} else {
+			h.setFlash(c, "success", "Backup restored and CoreDNS reloaded")
+		}
+	} else {
+		h.setFlash(c, "success", "Backup from "+id+" restored")
+	}
+	return c.Redirect(http.StatusSeeOther, "/zones/"+domain)
+}
@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+
+	"simple-coredns-manager/internal/coredns"
+
+	"github.com/labstack/echo/v4"
+)
+
+type ZonesBulkData struct {
+	Domains     string
+	Records     string
+	ZonePath    string
+	AddCorefile bool
+	Results     []BulkResult
+	Summary     string
+}
+
+type BulkResult struct {
+	Domain string
+	Status string // created, skipped, failed
+	Detail string
+}
+
+// ZonesBulkPage shows the bulk zone creation form.
+func (h *Handler) ZonesBulkPage(c echo.Context) error {
+	data := ZonesBulkData{ZonePath: h.corefileZoneDir(), AddCorefile: true}
+	pd := h.page(c, "Bulk Create Zones", "zones", data)
+	return c.Render(http.StatusOK, "zones_bulk", pd)
+}
+
+// ZonesBulkCreate creates a zone for every listed domain from the default
+// template plus optional shared records. Existing zones are skipped, and each
+// domain is reported separately so partial failures are visible. Corefile
+// blocks are added in a single write and CoreDNS is reloaded at most once.
+func (h *Handler) ZonesBulkCreate(c echo.Context) error {
+	data := ZonesBulkData{
+		Domains:     c.FormValue("domains"),
+		Records:     strings.TrimSpace(c.FormValue("records")),
+		ZonePath:    strings.TrimSpace(c.FormValue("zone_path")),
+		AddCorefile: c.FormValue("add_corefile") == "true",
+	}
+	if data.ZonePath == "" {
+		data.ZonePath = h.corefileZoneDir()
+	}
+
+	domains := strings.FieldsFunc(data.Domains, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\n' || r == '\r' || r == '\t'
+	})
+	if len(domains) == 0 {
+		h.setFlash(c, "error", "Enter at least one domain")
+		return c.Redirect(http.StatusSeeOther, "/zones/bulk")
+	}
+
+	h.mu.Lock()
+	var created []string
+	seen := map[string]bool{}
+	for _, domain := range domains {
+		domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+		if seen[domain] {
+			continue
+		}
+		seen[domain] = true
+
+		res := BulkResult{Domain: domain}
+		if coredns.ValidateDomain(domain) == nil && h.Zones.Exists(domain) {
+			res.Status, res.Detail = "skipped", "zone already exists"
+		} else if err := h.createFromTemplate(domain, data.Records); err != nil {
+			res.Status, res.Detail = "failed", err.Error()
+		} else {
+			res.Status = "created"
+			created = append(created, domain)
+		}
+		data.Results = append(data.Results, res)
+	}
+
+	corefileMsg, blocks := "", 0
+	if data.AddCorefile && len(created) > 0 {
+		n, err := h.addCorefileBlocks(created, data.ZonePath)
+		if err != nil {
+			corefileMsg = "; Corefile not updated: " + err.Error()
+		} else {
+			corefileMsg = fmt.Sprintf("; %d Corefile block(s) added", n)
+			blocks = n
+		}
+	}
+	h.mu.Unlock()
+
+	// audit commits to git under h.mu itself
+	for _, domain := range created {
+		h.audit(c, "zone.create", domain, "bulk")
+	}
+	if blocks > 0 {
+		h.audit(c, "corefile.save", "Corefile", fmt.Sprintf("%d server block(s) added", blocks))
+	}
+
+	data.Summary = fmt.Sprintf("%d of %d zone(s) created%s", len(created), len(data.Results), corefileMsg)
+	if len(created) > 0 && h.wantsReload(c) {
+		if err := h.reloadCoreDNS(c); err != nil {
+			data.Summary += "; reload failed: " + err.Error()
+		} else {
+			data.Summary += "; CoreDNS reloaded"
+		}
+	}
+
+	pd := h.page(c, "Bulk Create Zones", "zones", data)
+	return c.Render(http.StatusOK, "zones_bulk", pd)
+}
+
+// createFromTemplate writes a new zone from the default template with the
+// shared records appended. Callers must hold h.mu.
+func (h *Handler) createFromTemplate(domain, records string) error {
+	content, err := h.Zones.PreviewCreate(domain)
+	if err != nil {
+		return err
+	}
+	if records != "" {
+		content += "\n" + records + "\n"
+	}
+	if err := h.Zones.Validate(domain, content); err != nil {
+		return err
+	}
+	return h.Zones.Write(domain, content, "")
+}
+
+// addCorefileBlocks appends a server block for each domain the Corefile
+// doesn't already serve from a zone file, and returns how many were added.
+func (h *Handler) addCorefileBlocks(domains []string, zonePath string) (int, error) {
+	content, err := h.Corefile.Read()
+	if err != nil {
+		return 0, err
+	}
+
+	served := map[string]bool{}
+	for _, line := range h.Corefile.Annotate(content, h.Zones) {
+		if line.Domain != "" {
+			served[line.Domain] = true
+		}
+	}
+
+	var b strings.Builder
+	added := 0
+	for _, domain := range domains {
+		if served[domain] {
+			continue
+		}
+		fmt.Fprintf(&b, "\n%s {\n    file %s\n    log\n    errors\n}\n", domain, path.Join(zonePath, "db."+domain))
+		added++
+	}
+	if added == 0 {
+		return 0, nil
+	}
+
+	content = strings.TrimRight(content, "\n") + "\n" + b.String()
+	if err := h.Corefile.Validate(content); err != nil {
+		return 0, err
+	}
+	return added, h.Corefile.Write(content)
+}
+
+// corefileZoneDir guesses the zone directory as CoreDNS sees it from the
+// first managed `file` directive, since it usually differs from ZONE_DIR
+// inside the manager's container.
+func (h *Handler) corefileZoneDir() string {
+	if content, err := h.Corefile.Read(); err == nil {
+		for _, line := range h.Corefile.Annotate(content, h.Zones) {
+			if line.Managed {
+				return path.Dir(line.Path)
+			}
+		}
+	}
+	return "/etc/coredns"
+}
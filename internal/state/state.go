@@ -0,0 +1,221 @@
+package state
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// maxImportSize caps the total uncompressed size of an imported archive.
+const maxImportSize = 512 << 20
+
+// Export writes the contents of dir as a gzipped tar stream. Files listed in
+// secrets, such as the master password hash, are left out.
+func Export(dir string, w io.Writer, secrets ...string) error {
+	skip := relPaths(dir, secrets)
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		// Skip leftovers from an interrupted import
+		if strings.HasPrefix(d.Name(), ".import-") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if slices.Contains(skip, filepath.ToSlash(rel)) {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() && !info.IsDir() {
+			return nil
+		}
+
+		hdr, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to archive state directory: %w", err)
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// Import validates a gzipped tar produced by Export and replaces the
+// matching entries in dir. The archive is fully extracted to a staging
+// directory first, so a malformed archive leaves dir untouched. Files listed
+// in secrets are never replaced or created, even when the archive has them.
+func Import(dir string, r io.Reader, secrets ...string) error {
+	staging, err := os.MkdirTemp(dir, ".import-*")
+	if err != nil {
+		return fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	defer os.RemoveAll(staging)
+
+	if err := extract(staging, r); err != nil {
+		return err
+	}
+	if err := keepSecrets(dir, staging, relPaths(dir, secrets)); err != nil {
+		return err
+	}
+
+	entries, err := os.ReadDir(staging)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		dst := filepath.Join(dir, e.Name())
+		if err := os.RemoveAll(dst); err != nil {
+			return fmt.Errorf("failed to replace %s: %w", e.Name(), err)
+		}
+		if err := os.Rename(filepath.Join(staging, e.Name()), dst); err != nil {
+			return fmt.Errorf("failed to replace %s: %w", e.Name(), err)
+		}
+	}
+	return nil
+}
+
+// relPaths returns the paths that are inside dir, relative to it with
+// forward slashes.
+func relPaths(dir string, paths []string) []string {
+	var rels []string
+	dir, _ = filepath.Abs(dir)
+	for _, p := range paths {
+		if p == "" {
+			continue
+		}
+		p, _ = filepath.Abs(p)
+		rel, err := filepath.Rel(dir, p)
+		if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		rels = append(rels, filepath.ToSlash(rel))
+	}
+	return rels
+}
+
+// keepSecrets drops the secrets from the extracted archive and, where the
+// import replaces a directory holding one, carries the current file over so
+// the swap doesn't lose it.
+func keepSecrets(dir, staging string, secrets []string) error {
+	for _, rel := range secrets {
+		staged := filepath.Join(staging, filepath.FromSlash(rel))
+		if err := os.RemoveAll(staged); err != nil {
+			return err
+		}
+		top, _, nested := strings.Cut(rel, "/")
+		if !nested {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(staging, top)); err != nil {
+			continue // the directory isn't replaced
+		}
+		current := filepath.Join(dir, filepath.FromSlash(rel))
+		info, err := os.Stat(current)
+		if err != nil {
+			continue
+		}
+		data, err := os.ReadFile(current)
+		if err != nil {
+			return fmt.Errorf("failed to keep %s: %w", rel, err)
+		}
+		if err := os.MkdirAll(filepath.Dir(staged), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(staged, data, info.Mode().Perm()); err != nil {
+			return fmt.Errorf("failed to keep %s: %w", rel, err)
+		}
+	}
+	return nil
+}
+
+func extract(dst string, r io.Reader) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("not a gzip archive: %w", err)
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	var total int64
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("invalid archive: %w", err)
+		}
+
+		name := path.Clean(hdr.Name)
+		if name == "." || path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return fmt.Errorf("invalid archive entry %q", hdr.Name)
+		}
+		target := filepath.Join(dst, filepath.FromSlash(name))
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0o755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			total += hdr.Size
+			if total > maxImportSize {
+				return fmt.Errorf("archive exceeds %d MB", maxImportSize>>20)
+			}
+			if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+				return err
+			}
+			f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, hdr.FileInfo().Mode().Perm())
+			if err != nil {
+				return err
+			}
+			_, err = io.CopyN(f, tr, hdr.Size)
+			f.Close()
+			if err != nil {
+				return fmt.Errorf("failed to extract %s: %w", name, err)
+			}
+		default:
+			return fmt.Errorf("unsupported archive entry %q (only files and directories allowed)", hdr.Name)
+		}
+	}
+}
@@ -35,6 +35,10 @@ func NewRenderer(templatesDir string) (*Renderer, error) {
 				return "secondary"
 			case "NS":
 				return "light"
+			case "CAA":
+				return "danger"
+			case "PTR":
+				return "primary"
 			default:
 				return "dark"
 			}
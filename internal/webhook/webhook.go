@@ -0,0 +1,139 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+// SignatureHeader carries "sha256=<hex HMAC of the body>" when a secret is
+// configured.
+const SignatureHeader = "X-Webhook-Signature"
+
+const (
+	attemptTimeout = 10 * time.Second
+	maxAttempts    = 3
+	retryDelay     = 2 * time.Second // grows with each attempt
+	// queueSize is how many events may wait for delivery; more are dropped
+	// and logged rather than piling up while the receiver is down
+	queueSize = 100
+)
+
+// Event is the JSON payload posted for a change.
+type Event struct {
+	Action    string    `json:"action"`           // same names as the audit log, e.g. "record.add"
+	Domain    string    `json:"domain,omitempty"` // zone domain or file name
+	User      string    `json:"user"`
+	Timestamp time.Time `json:"timestamp"`
+	Detail    string    `json:"detail,omitempty"`
+	RequestID string    `json:"request_id,omitempty"`
+}
+
+// Notifier posts events to a URL in the background, one at a time and in
+// order. A nil *Notifier sends nothing.
+type Notifier struct {
+	url        string
+	secret     []byte
+	client     *http.Client
+	retryDelay time.Duration
+	queue      chan queued
+}
+
+type queued struct {
+	event Event
+	body  []byte
+}
+
+// New returns a Notifier for url, or nil if url is empty.
+func New(url string, secret []byte) *Notifier {
+	if url == "" {
+		return nil
+	}
+	n := &Notifier{
+		url:        url,
+		secret:     secret,
+		client:     &http.Client{Timeout: attemptTimeout},
+		retryDelay: retryDelay,
+		queue:      make(chan queued, queueSize),
+	}
+	go n.run()
+	return n
+}
+
+// Send queues e for delivery without waiting for the result. Failed
+// deliveries are retried a couple of times and then logged; when the queue
+// is full the event is dropped and logged.
+func (n *Notifier) Send(e Event) {
+	if n == nil {
+		return
+	}
+	if e.Timestamp.IsZero() {
+		e.Timestamp = time.Now().UTC()
+	}
+	body, err := json.Marshal(e)
+	if err != nil {
+		log.Printf("webhook: %v", err)
+		return
+	}
+	select {
+	case n.queue <- queued{event: e, body: body}:
+	default:
+		log.Printf("webhook: dropping %s %s, %d events are already waiting", e.Action, e.Domain, queueSize)
+	}
+}
+
+func (n *Notifier) run() {
+	for q := range n.queue {
+		n.deliver(q)
+	}
+}
+
+func (n *Notifier) deliver(q queued) {
+	var err error
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		if attempt > 1 {
+			time.Sleep(time.Duration(attempt-1) * n.retryDelay)
+		}
+		if err = n.post(q.body); err == nil {
+			return
+		}
+	}
+	log.Printf("webhook: giving up on %s %s after %d attempts: %v", q.event.Action, q.event.Domain, maxAttempts, err)
+}
+
+func (n *Notifier) post(body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "simple-coredns-manager")
+	if len(n.secret) > 0 {
+		req.Header.Set(SignatureHeader, "sha256="+Sign(n.secret, body))
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s returned %s", n.url, resp.Status)
+	}
+	return nil
+}
+
+// Sign returns the hex HMAC-SHA256 of body, as sent in SignatureHeader.
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
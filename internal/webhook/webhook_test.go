@@ -0,0 +1,105 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testNotifier returns a Notifier for url that retries without waiting.
+func testNotifier(url string, secret []byte) *Notifier {
+	n := New(url, secret)
+	n.retryDelay = time.Millisecond
+	return n
+}
+
+// received waits for a delivered event.
+func received(t *testing.T, events <-chan Event) Event {
+	t.Helper()
+	select {
+	case e := <-events:
+		return e
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event delivered")
+		return Event{}
+	}
+}
+
+func TestSendSignature(t *testing.T) {
+	secret := []byte("s3cret")
+	events := make(chan Event, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if got, want := r.Header.Get(SignatureHeader), "sha256="+Sign(secret, body); got != want {
+			t.Errorf("%s = %q, want %q", SignatureHeader, got, want)
+		}
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Content-Type = %q", ct)
+		}
+		var e Event
+		if err := json.Unmarshal(body, &e); err != nil {
+			t.Errorf("body is not an event: %v", err)
+		}
+		events <- e
+	}))
+	defer srv.Close()
+
+	testNotifier(srv.URL, secret).Send(Event{Action: "record.add", Domain: "example.com", User: "alice"})
+	e := received(t, events)
+	if e.Action != "record.add" || e.Domain != "example.com" || e.User != "alice" || e.Timestamp.IsZero() {
+		t.Errorf("delivered %+v", e)
+	}
+}
+
+func TestSendRetry(t *testing.T) {
+	var attempts atomic.Int32
+	events := make(chan Event, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) < maxAttempts {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.Header.Get(SignatureHeader) != "" {
+			t.Error("signature sent without a secret")
+		}
+		events <- Event{}
+	}))
+	defer srv.Close()
+
+	testNotifier(srv.URL, nil).Send(Event{Action: "zone.create"})
+	received(t, events)
+	if n := attempts.Load(); n != maxAttempts {
+		t.Errorf("delivered after %d attempts, want %d", n, maxAttempts)
+	}
+}
+
+func TestSendTimeout(t *testing.T) {
+	var attempts atomic.Int32
+	events := make(chan Event, 1)
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) == 1 {
+			// Hang past the client timeout
+			select {
+			case <-r.Context().Done():
+			case <-release:
+			}
+			return
+		}
+		events <- Event{}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	n := testNotifier(srv.URL, nil)
+	n.client.Timeout = 50 * time.Millisecond
+	n.Send(Event{Action: "zone.delete"})
+	received(t, events)
+	if got := attempts.Load(); got != 2 {
+		t.Errorf("delivered after %d attempts, want 2", got)
+	}
+}
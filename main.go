@@ -2,12 +2,17 @@ package main
 
 import (
 	"log"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"simple-coredns-manager/internal/auth"
 	"simple-coredns-manager/internal/config"
 	"simple-coredns-manager/internal/coredns"
 	"simple-coredns-manager/internal/docker"
+	"simple-coredns-manager/internal/git"
 	"simple-coredns-manager/internal/handlers"
 	"simple-coredns-manager/internal/templates"
 
@@ -27,28 +32,81 @@ func main() {
 		log.Fatalf("Template error: %v", err)
 	}
 
-	dockerClient := docker.NewClient(cfg.CoreDNSContainerName)
+	dockerClient := docker.NewClient(cfg.CoreDNSContainerName, docker.Options{
+		Binary:        cfg.CoreDNSBinary,
+		Strategy:      cfg.ReloadStrategy,
+		ReloadCommand: cfg.ReloadCommand,
+	})
 	if !dockerClient.Available() {
 		log.Println("WARNING: Docker socket not available — reload features disabled")
 	} else {
 		log.Println("Docker socket connected")
 	}
 
-	corefileManager := coredns.NewCorefileManager(cfg.CorefilePath)
-	zoneManager := coredns.NewZoneManager(cfg.ZoneDir)
+	if cfg.ReadOnly {
+		log.Println("Read-only mode — changes are disabled")
+	} else {
+		if err := coredns.CheckWritable(cfg.ZoneDir); err != nil {
+			log.Printf("WARNING: zone directory check failed — saves will fail: %v", err)
+		}
+		if err := coredns.CheckWritable(filepath.Dir(cfg.CorefilePath)); err != nil {
+			log.Printf("WARNING: Corefile directory check failed — saves will fail: %v", err)
+		}
+	}
+
+	backups := coredns.NewBackups(cfg.BackupDir, cfg.BackupKeep)
+	corefileManager := coredns.NewCorefileManager(cfg.CorefilePath, backups)
+	zoneManager := coredns.NewZoneManager(cfg.ZoneDir, coredns.ZoneOptions{
+		ManagedHeader:    cfg.ManagedHeader,
+		StrictNames:      cfg.StrictRecordNames,
+		NormalizeTargets: cfg.NormalizeTargets,
+		EpochSerials:     cfg.SOASerialMode == config.SerialEpoch,
+		Backups:          backups,
+	})
+
+	keyring := auth.NewKeyring(cfg.JWTSecret, cfg.JWTSecretSecondary)
 
-	h := handlers.NewHandler(cfg, corefileManager, zoneManager, dockerClient)
+	var users *auth.Users
+	if cfg.UsersFile != "" {
+		users, err = auth.LoadUsers(cfg.UsersFile)
+		if err != nil {
+			log.Fatalf("Users error: %v", err)
+		}
+	}
+	var repo *git.Repo
+	if cfg.GitRepoDir != "" {
+		repo, err = git.Open(cfg.GitRepoDir, cfg.ZoneDir, filepath.Dir(cfg.CorefilePath))
+		if err != nil {
+			log.Fatalf("GIT_REPO_DIR error: %v", err)
+		}
+		log.Printf("Committing changes to the git repository at %s", repo.Dir())
+	}
+	h := handlers.NewHandler(cfg, corefileManager, zoneManager, dockerClient, keyring, users, repo)
 
 	e := echo.New()
 	e.HideBanner = true
 	e.Renderer = renderer
 
 	e.Use(middleware.Recover())
+	// Short request IDs are logged and quoted in error messages so a user's
+	// report can be matched to its log line
+	e.Use(middleware.RequestIDWithConfig(middleware.RequestIDConfig{
+		Generator: handlers.NewRequestID,
+	}))
 	e.Use(middleware.Logger())
+	// Restores carry whole archives and get their own, higher limit below
+	e.Use(handlers.BodyLimit(cfg.BodyLimit, "BODY_LIMIT", func(c echo.Context) bool {
+		return c.Path() == "/state/import" || c.Path() == "/restore"
+	}))
 	e.Use(middleware.CSRFWithConfig(middleware.CSRFConfig{
+		// Bearer-authenticated API calls carry no cookies to forge
+		Skipper: func(c echo.Context) bool {
+			return strings.HasPrefix(c.Request().URL.Path, "/api/") && strings.HasPrefix(c.Request().Header.Get("Authorization"), "Bearer ")
+		},
 		ContextKey:     "csrf",
 		TokenLookup:    "form:_csrf,header:X-CSRF-Token",
-		CookieName:     "_csrf",
+		CookieName:     h.Cookies.Name("_csrf"),
+		CookieDomain:   h.Cookies.Domain,
 		CookiePath:     "/",
 		CookieHTTPOnly: true,
 		CookieSameSite: 4, // http.SameSiteStrictMode
@@ -73,23 +131,118 @@ func main() {
 	e.POST("/login", h.LoginSubmit, loginLimiter)
 
 	// Authenticated routes
-	authed := e.Group("", auth.Middleware(cfg.JWTSecret))
+	authed := e.Group("", auth.Middleware(keyring, h.Cookies, h.TokenGeneration))
+	if cfg.ReadOnly {
+		authed.Use(handlers.ReadOnly("/logout", "/corefile/preview", "/corefile/diff", "/zones/new/template",
+			"/zones/:domain/preview", "/zones/:domain/diff", "/scratchpad", "/dig", "/resolve"))
+	}
 	authed.POST("/logout", h.Logout)
 	authed.GET("/", h.Dashboard)
+	authed.GET("/status", h.StatusJSON)
+	authed.GET("/status/coredns", h.CoreDNSInfo)
 	authed.GET("/corefile", h.CorefileEdit)
+	authed.GET("/corefile/running", h.CorefileRunning)
 	authed.POST("/corefile/preview", h.CorefilePreview)
+	authed.POST("/corefile/diff", h.CorefileDiff)
 	authed.POST("/corefile/save", h.CorefileSave)
 	authed.GET("/zones", h.ZonesList)
+	authed.GET("/export/zones", h.ZonesExportAll)
+	authed.GET("/zones/bulk", h.ZonesBulkPage)
+	authed.POST("/zones/bulk", h.ZonesBulkCreate)
 	authed.GET("/zones/new", h.ZonesNew)
+	authed.POST("/zones/new/template", h.ZonesNewTemplate)
 	authed.GET("/zones/:domain", h.ZonesEdit)
+	authed.GET("/zones/:domain/live-serial", h.ZonesLiveSerial)
+	authed.GET("/zones/:domain/changed", h.ZonesChanged)
+	authed.GET("/zones/:domain/backups", h.ZonesBackups)
+	authed.POST("/zones/:domain/backups/restore", h.ZonesBackupRestore)
+	authed.POST("/zones/:domain/undo", h.ZonesUndo)
 	authed.POST("/zones/:domain/preview", h.ZonesPreview)
+	authed.POST("/zones/:domain/diff", h.ZonesDiff)
 	authed.POST("/zones/:domain/save", h.ZonesSave)
+	authed.POST("/zones/:domain/upload", h.ZonesUpload)
+	authed.POST("/zones/:domain/upload/confirm", h.ZonesUploadConfirm)
+	authed.POST("/zones/:domain/rename", h.ZonesRename)
+	authed.POST("/zones/:domain/rename/confirm", h.ZonesRenameConfirm)
+	authed.POST("/zones/:domain/clone", h.ZonesClone)
 	authed.POST("/zones/:domain/delete", h.ZonesDelete)
+	authed.POST("/zones/:domain/soa", h.ZonesSOA)
+	authed.GET("/zones/:domain/ds", h.ZonesDS)
+	authed.GET("/zones/:domain/export", h.ZonesExport)
+	authed.POST("/zones/:domain/import", h.ZonesImport)
 	authed.POST("/zones/:domain/record/add", h.ZonesAddRecord)
+	authed.POST("/zones/:domain/record/update", h.ZonesUpdateRecord)
+	authed.POST("/zones/:domain/record/email", h.ZonesAddEmailRecord)
 	authed.POST("/zones/:domain/record/delete", h.ZonesRemoveRecord)
+	authed.GET("/search", h.SearchPage)
+	authed.GET("/scratchpad", h.ScratchpadPage)
+	authed.POST("/scratchpad", h.ScratchpadValidate)
+	authed.GET("/state", h.StatePage)
+	authed.GET("/state/export", h.StateExport)
+	authed.POST("/state/import", h.StateImport, handlers.BodyLimit(cfg.RestoreBodyLimit, "RESTORE_BODY_LIMIT", nil))
+	authed.GET("/backup", h.ConfigBackup)
+	authed.POST("/restore", h.ConfigRestore, handlers.BodyLimit(cfg.RestoreBodyLimit, "RESTORE_BODY_LIMIT", nil))
+	authed.GET("/audit", h.AuditPage)
+	authed.GET("/account/password", h.AccountPasswordPage)
+	authed.POST("/account/password", h.AccountPasswordChange)
+	authed.GET("/admin/jwt", h.JWTRotatePage)
+	authed.POST("/admin/jwt/rotate", h.JWTRotate)
 	authed.GET("/dig", h.DigPage)
 	authed.POST("/dig", h.DigQuery)
+	authed.GET("/resolve", h.ResolveJSON)
+	authed.POST("/resolve", h.ResolveFiles)
+	authed.GET("/reload", h.ReloadConfirm)
+	authed.GET("/reload/diff", h.ReloadDiff)
 	authed.POST("/reload", h.Reload)
 
-	e.Logger.Fatal(e.Start(":" + cfg.Port))
+	// JSON API
+	api := e.Group("/api/v1", auth.APIMiddleware(keyring, h.Cookies, h.TokenGeneration, cfg.APITokens))
+	if cfg.ReadOnly {
+		api.Use(handlers.ReadOnly())
+	}
+	api.GET("/whoami", h.APIWhoami)
+	api.GET("/inventory", h.APIInventory)
+	api.GET("/zones", h.APIZones)
+	api.POST("/zones", h.APIZoneCreate)
+	api.DELETE("/zones/:domain", h.APIZoneDelete)
+	api.GET("/zones/:domain/records", h.APIRecords)
+	api.POST("/zones/:domain/records", h.APIRecordAdd)
+	api.PUT("/zones/:domain/records", h.APIRecordUpdate)
+	api.DELETE("/zones/:domain/records", h.APIRecordDelete)
+
+	// Bind every address up front so a bad one fails startup before any
+	// listener starts serving
+	listeners := make([]net.Listener, 0, len(cfg.ListenAddrs))
+	for _, addr := range cfg.ListenAddrs {
+		network := listenNetwork(addr)
+		ln, err := net.Listen(network, addr)
+		if err != nil {
+			log.Fatalf("Failed to listen on %s (%s): %v", addr, network, err)
+		}
+		log.Printf("Listening on %s (%s)", ln.Addr(), network)
+		listeners = append(listeners, ln)
+	}
+	for _, ln := range listeners[1:] {
+		go func(ln net.Listener) {
+			log.Fatal((&http.Server{Handler: e}).Serve(ln))
+		}(ln)
+	}
+	e.Listener = listeners[0]
+	e.Logger.Fatal(e.Start(""))
+}
+
+// listenNetwork picks tcp4 or tcp6 for literal IP hosts so "0.0.0.0:8080"
+// and "[::]:8080" can be bound side by side; other hosts use plain tcp,
+// which is dual-stack where the OS allows it.
+func listenNetwork(addr string) string {
+	host, _, _ := net.SplitHostPort(addr)
+	ip := net.ParseIP(host)
+	switch {
+	case ip == nil:
+		return "tcp"
+	case ip.To4() != nil:
+		return "tcp4"
+	default:
+		return "tcp6"
+	}
 }